@@ -0,0 +1,217 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"sigs.k8s.io/yaml"
+
+	"k8s-controller-custom-resource/election"
+)
+
+// Config holds every tunable of the controller. It is read from an optional
+// YAML file, environment variables and flags, see Loader
+type Config struct {
+	// Kubeconfig and Context select the cluster, KubeAPIQPS, KubeAPIBurst
+	// and UserAgent tune the clients when they are set
+	Kubeconfig   string  `json:"kubeconfig,omitempty"`
+	Context      string  `json:"context,omitempty"`
+	KubeAPIQPS   float64 `json:"kubeAPIQPS,omitempty"`
+	KubeAPIBurst int     `json:"kubeAPIBurst,omitempty"`
+	UserAgent    string  `json:"userAgent,omitempty"`
+
+	// Namespace limits the controller to one namespace, empty means all
+	Namespace string `json:"namespace,omitempty"`
+	// ResyncPeriod is how often the informers resync, 0 disables resyncs
+	ResyncPeriod metav1.Duration `json:"resyncPeriod"`
+	// Workers is the number of MyResources reconciled in parallel
+	Workers int `json:"workers"`
+	// MaxRetries is how often a failed reconcile is retried before giving up
+	MaxRetries int `json:"maxRetries"`
+
+	// MetricsBindAddress is the address serving the Prometheus metrics on
+	// /metrics, empty disables the endpoint
+	MetricsBindAddress string `json:"metricsBindAddress"`
+	// HealthProbeBindAddress is the address serving the /healthz and
+	// /readyz probes, empty disables them
+	HealthProbeBindAddress string `json:"healthProbeBindAddress"`
+	// CacheSyncTimeout is how long the controller waits for its informers
+	// to sync before it exits, 0 waits forever
+	CacheSyncTimeout metav1.Duration `json:"cacheSyncTimeout"`
+	// WorkerStallTimeout is how long a single reconcile may take before
+	// the liveness probe fails
+	WorkerStallTimeout metav1.Duration `json:"workerStallTimeout"`
+	// WebhookBindAddress is the address serving the webhooks of the CRD
+	// over TLS, empty disables them. WebhookCertFile and WebhookKeyFile
+	// are the PEM files of the serving certificate
+	WebhookBindAddress string `json:"webhookBindAddress,omitempty"`
+	WebhookCertFile    string `json:"webhookCertFile,omitempty"`
+	WebhookKeyFile     string `json:"webhookKeyFile,omitempty"`
+
+	RateLimiter    RateLimiterConfig    `json:"rateLimiter"`
+	LeaderElection LeaderElectionConfig `json:"leaderElection"`
+}
+
+// RateLimiterConfig configures the rate limiter of the queue, a failed key
+// is delayed exponentially from BaseDelay up to MaxDelay and all keys share
+// a bucket of QPS and Burst
+type RateLimiterConfig struct {
+	BaseDelay metav1.Duration `json:"baseDelay"`
+	MaxDelay  metav1.Duration `json:"maxDelay"`
+	QPS       float64         `json:"qps"`
+	Burst     int             `json:"burst"`
+}
+
+// LeaderElectionConfig configures the leader election among the replicas
+type LeaderElectionConfig struct {
+	Enabled       bool            `json:"enabled"`
+	ResourceLock  string          `json:"resourceLock"`
+	Namespace     string          `json:"namespace"`
+	Name          string          `json:"name"`
+	LeaseDuration metav1.Duration `json:"leaseDuration"`
+	RenewDeadline metav1.Duration `json:"renewDeadline"`
+	RetryPeriod   metav1.Duration `json:"retryPeriod"`
+}
+
+// Default returns the Config used for everything that is not set
+func Default() *Config {
+	return &Config{
+		Namespace:    metav1.NamespaceAll,
+		ResyncPeriod: metav1.Duration{Duration: 0},
+		Workers:      2,
+		MaxRetries:   5,
+
+		MetricsBindAddress:     ":8080",
+		HealthProbeBindAddress: ":8081",
+		CacheSyncTimeout:       metav1.Duration{Duration: 2 * time.Minute},
+		WorkerStallTimeout:     metav1.Duration{Duration: 5 * time.Minute},
+		// the same as workqueue.DefaultControllerRateLimiter
+		RateLimiter: RateLimiterConfig{
+			BaseDelay: metav1.Duration{Duration: 5 * time.Millisecond},
+			MaxDelay:  metav1.Duration{Duration: 1000 * time.Second},
+			QPS:       10,
+			Burst:     100,
+		},
+		LeaderElection: LeaderElectionConfig{
+			ResourceLock:  election.LeasesResourceLock,
+			Namespace:     metav1.NamespaceDefault,
+			Name:          "myresource-controller",
+			LeaseDuration: metav1.Duration{Duration: 15 * time.Second},
+			RenewDeadline: metav1.Duration{Duration: 10 * time.Second},
+			RetryPeriod:   metav1.Duration{Duration: 2 * time.Second},
+		},
+	}
+}
+
+// Validate returns every invalid value or combination of values at once
+func (c *Config) Validate() error {
+	var errs []string
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if c.KubeAPIQPS < 0 {
+		invalid("kube-api-qps must not be negative, got %v", c.KubeAPIQPS)
+	}
+	if c.KubeAPIBurst < 0 {
+		invalid("kube-api-burst must not be negative, got %d", c.KubeAPIBurst)
+	}
+	if c.KubeAPIQPS > 0 && c.KubeAPIBurst > 0 && float64(c.KubeAPIBurst) < c.KubeAPIQPS {
+		invalid("kube-api-burst (%d) must be at least kube-api-qps (%v)", c.KubeAPIBurst, c.KubeAPIQPS)
+	}
+	if c.Namespace != metav1.NamespaceAll {
+		for _, msg := range validation.IsDNS1123Label(c.Namespace) {
+			invalid("namespace %q is invalid: %s", c.Namespace, msg)
+		}
+	}
+	if c.ResyncPeriod.Duration < 0 {
+		invalid("resync-period must not be negative, got %v", c.ResyncPeriod.Duration)
+	}
+	if c.Workers < 1 {
+		invalid("workers must be at least 1, got %d", c.Workers)
+	}
+	if c.MaxRetries < 1 {
+		invalid("max-retries must be at least 1, got %d", c.MaxRetries)
+	}
+
+	addresses := []struct{ name, address string }{
+		{"metrics-bind-address", c.MetricsBindAddress},
+		{"health-probe-bind-address", c.HealthProbeBindAddress},
+		{"webhook-bind-address", c.WebhookBindAddress},
+	}
+	for i, a := range addresses {
+		if a.address == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(a.address); err != nil {
+			invalid("%s %q is invalid: %v", a.name, a.address, err)
+		}
+		for _, b := range addresses[i+1:] {
+			if a.address == b.address {
+				invalid("%s and %s must differ, both are %q", a.name, b.name, a.address)
+			}
+		}
+	}
+	if c.WebhookBindAddress != "" && (c.WebhookCertFile == "" || c.WebhookKeyFile == "") {
+		invalid("webhook-tls-cert-file and webhook-tls-key-file are required with webhook-bind-address")
+	}
+	if c.CacheSyncTimeout.Duration < 0 {
+		invalid("cache-sync-timeout must not be negative, got %v", c.CacheSyncTimeout.Duration)
+	}
+	if c.WorkerStallTimeout.Duration <= 0 {
+		invalid("worker-stall-timeout must be positive, got %v", c.WorkerStallTimeout.Duration)
+	}
+
+	limiter := c.RateLimiter
+	if limiter.BaseDelay.Duration <= 0 {
+		invalid("rate-limiter-base-delay must be positive, got %v", limiter.BaseDelay.Duration)
+	}
+	if limiter.MaxDelay.Duration < limiter.BaseDelay.Duration {
+		invalid("rate-limiter-max-delay (%v) must be at least rate-limiter-base-delay (%v)",
+			limiter.MaxDelay.Duration, limiter.BaseDelay.Duration)
+	}
+	if limiter.QPS <= 0 {
+		invalid("rate-limiter-qps must be positive, got %v", limiter.QPS)
+	}
+	if limiter.Burst < 1 {
+		invalid("rate-limiter-burst must be at least 1, got %d", limiter.Burst)
+	}
+
+	leaderElection := c.LeaderElection
+	if leaderElection.Enabled {
+		if leaderElection.ResourceLock != election.LeasesResourceLock && leaderElection.ResourceLock != resourcelock.ConfigMapsResourceLock {
+			invalid("leader-elect-resource-lock must be leases or configmaps, got %q", leaderElection.ResourceLock)
+		}
+		for _, msg := range validation.IsDNS1123Label(leaderElection.Namespace) {
+			invalid("leader-elect-namespace %q is invalid: %s", leaderElection.Namespace, msg)
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(leaderElection.Name) {
+			invalid("leader-elect-name %q is invalid: %s", leaderElection.Name, msg)
+		}
+		if leaderElection.LeaseDuration.Duration <= leaderElection.RenewDeadline.Duration {
+			invalid("leader-elect-lease-duration (%v) must be greater than leader-elect-renew-deadline (%v)",
+				leaderElection.LeaseDuration.Duration, leaderElection.RenewDeadline.Duration)
+		}
+		retryPeriod := time.Duration(leaderelection.JitterFactor * float64(leaderElection.RetryPeriod.Duration))
+		if leaderElection.RetryPeriod.Duration <= 0 || leaderElection.RenewDeadline.Duration <= retryPeriod {
+			invalid("leader-elect-renew-deadline (%v) must be greater than %v times leader-elect-retry-period (%v)",
+				leaderElection.RenewDeadline.Duration, leaderelection.JitterFactor, leaderElection.RetryPeriod.Duration)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// YAML returns the Config in the format of the config file
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
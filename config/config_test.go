@@ -0,0 +1,165 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// load parses the arguments like a command would, with env as the whole
+// environment
+func load(t *testing.T, args []string, env map[string]string) (*Config, error) {
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := NewLoader(flagSet)
+	loader.LookupEnv = func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+	if err := flagSet.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return loader.Load()
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	config, err := load(t, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, Default(), config)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+namespace: from-file
+workers: 3
+maxRetries: 7
+resyncPeriod: 1m
+rateLimiter:
+  baseDelay: 10ms
+leaderElection:
+  enabled: true
+  name: from-file
+`)
+	env := map[string]string{
+		"MYRESOURCE_WORKERS":           "4",
+		"MYRESOURCE_MAX_RETRIES":       "8",
+		"MYRESOURCE_LEADER_ELECT_NAME": "from-env",
+	}
+
+	config, err := load(t, []string{"--config", path, "--workers=5"}, env)
+	assert.Nil(t, err)
+	// flags win over env vars, which win over the file
+	assert.Equal(t, 5, config.Workers)
+	assert.Equal(t, 8, config.MaxRetries)
+	assert.Equal(t, "from-env", config.LeaderElection.Name)
+	assert.Equal(t, "from-file", config.Namespace)
+	assert.Equal(t, time.Minute, config.ResyncPeriod.Duration)
+	assert.True(t, config.LeaderElection.Enabled)
+	// values missing from the file keep their defaults
+	assert.Equal(t, 10*time.Millisecond, config.RateLimiter.BaseDelay.Duration)
+	assert.Equal(t, Default().RateLimiter.MaxDelay, config.RateLimiter.MaxDelay)
+	assert.Equal(t, Default().LeaderElection.LeaseDuration, config.LeaderElection.LeaseDuration)
+
+	// the config file can come from the env too, and a flag set to the
+	// default value still overrides the file
+	env["MYRESOURCE_CONFIG"] = path
+	config, err = load(t, []string{"--namespace="}, env)
+	assert.Nil(t, err)
+	assert.Equal(t, "", config.Namespace)
+	assert.Equal(t, 4, config.Workers)
+}
+
+func TestLoadBoolFlag(t *testing.T) {
+	config, err := load(t, []string{"--leader-elect"}, map[string]string{"MYRESOURCE_LEADER_ELECT": "false"})
+	assert.Nil(t, err)
+	assert.True(t, config.LeaderElection.Enabled)
+}
+
+func TestLoadRejectsInvalidInput(t *testing.T) {
+	_, err := load(t, []string{"--config", writeConfigFile(t, "wokers: 3\n")}, nil)
+	assert.Contains(t, err.Error(), "wokers")
+
+	_, err = load(t, []string{"--config", filepath.Join(t.TempDir(), "missing.yaml")}, nil)
+	assert.NotNil(t, err)
+
+	_, err = load(t, nil, map[string]string{"MYRESOURCE_RESYNC_PERIOD": "often"})
+	assert.Contains(t, err.Error(), "MYRESOURCE_RESYNC_PERIOD")
+}
+
+func TestValidate(t *testing.T) {
+	for name, test := range map[string]struct {
+		mutate func(c *Config)
+		err    string
+	}{
+		"no workers":        {func(c *Config) { c.Workers = 0 }, "workers must be at least 1"},
+		"no retries":        {func(c *Config) { c.MaxRetries = 0 }, "max-retries must be at least 1"},
+		"negative resync":   {func(c *Config) { c.ResyncPeriod.Duration = -time.Second }, "resync-period"},
+		"invalid namespace": {func(c *Config) { c.Namespace = "Team_A" }, "namespace \"Team_A\" is invalid"},
+		"burst below qps": {func(c *Config) {
+			c.KubeAPIQPS = 50
+			c.KubeAPIBurst = 10
+		}, "kube-api-burst (10) must be at least kube-api-qps (50)"},
+		"max below base delay": {func(c *Config) { c.RateLimiter.MaxDelay.Duration = time.Millisecond },
+			"rate-limiter-max-delay"},
+		"metrics address without port": {func(c *Config) { c.MetricsBindAddress = "localhost" },
+			"metrics-bind-address \"localhost\" is invalid"},
+		"shared probe address": {func(c *Config) { c.HealthProbeBindAddress = c.MetricsBindAddress },
+			"must differ"},
+		"shared webhook address": {func(c *Config) {
+			c.WebhookBindAddress = c.HealthProbeBindAddress
+			c.WebhookCertFile = "tls.crt"
+			c.WebhookKeyFile = "tls.key"
+		}, "health-probe-bind-address and webhook-bind-address must differ"},
+		"webhook without certificate": {func(c *Config) { c.WebhookBindAddress = ":9443" },
+			"webhook-tls-cert-file and webhook-tls-key-file are required"},
+		"no stall timeout": {func(c *Config) { c.WorkerStallTimeout.Duration = 0 }, "worker-stall-timeout"},
+		"no bucket":        {func(c *Config) { c.RateLimiter.QPS = 0 }, "rate-limiter-qps must be positive"},
+		"unknown lock": {func(c *Config) {
+			c.LeaderElection.Enabled = true
+			c.LeaderElection.ResourceLock = "endpoints"
+		}, "leader-elect-resource-lock"},
+		"lease shorter than renew deadline": {func(c *Config) {
+			c.LeaderElection.Enabled = true
+			c.LeaderElection.LeaseDuration.Duration = 5 * time.Second
+		}, "leader-elect-lease-duration"},
+		"renew deadline shorter than retries": {func(c *Config) {
+			c.LeaderElection.Enabled = true
+			c.LeaderElection.RetryPeriod.Duration = 9 * time.Second
+		}, "leader-elect-renew-deadline"},
+	} {
+		config := Default()
+		test.mutate(config)
+		err := config.Validate()
+		if assert.NotNil(t, err, name) {
+			assert.Contains(t, err.Error(), test.err, name)
+		}
+	}
+
+	// the leader election settings only matter when it is enabled
+	config := Default()
+	config.LeaderElection.ResourceLock = "endpoints"
+	assert.Nil(t, config.Validate())
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	config := Default()
+	config.Namespace = "team-a"
+	config.LeaderElection.Enabled = true
+	data, err := config.YAML()
+	assert.Nil(t, err)
+
+	loaded, err := load(t, []string{"--config", writeConfigFile(t, string(data))}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, config, loaded)
+}
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// EnvPrefix prefixes the environment variable of every option, the rest is
+// the flag name in upper case with underscores, like MYRESOURCE_WORKERS
+const EnvPrefix = "MYRESOURCE_"
+
+// configFileFlag is the flag, and with EnvPrefix the environment variable,
+// naming the config file
+const configFileFlag = "config"
+
+// option is a tunable which can be set by a flag and an environment variable
+type option struct {
+	name  string
+	usage string
+	value func(c *Config) flag.Value
+}
+
+var options = []option{
+	{"kubeconfig", "Path of a kubeconfig file, defaults to the KUBECONFIG env var, the in-cluster config or ~/.kube/config",
+		func(c *Config) flag.Value { return (*stringValue)(&c.Kubeconfig) }},
+	{"context", "Name of the kubeconfig context to use",
+		func(c *Config) flag.Value { return (*stringValue)(&c.Context) }},
+	{"kube-api-qps", "Maximum queries per second to the API server, 0 keeps the client default",
+		func(c *Config) flag.Value { return (*float64Value)(&c.KubeAPIQPS) }},
+	{"kube-api-burst", "Maximum burst of queries to the API server, 0 keeps the client default",
+		func(c *Config) flag.Value { return (*intValue)(&c.KubeAPIBurst) }},
+	{"user-agent", "User agent sent to the API server, empty keeps the client default",
+		func(c *Config) flag.Value { return (*stringValue)(&c.UserAgent) }},
+	{"namespace", "Namespace of the MyResources to manage, empty for all namespaces",
+		func(c *Config) flag.Value { return (*stringValue)(&c.Namespace) }},
+	{"resync-period", "How often the informers resync all objects, 0 disables resyncs",
+		func(c *Config) flag.Value { return (*durationValue)(&c.ResyncPeriod) }},
+	{"workers", "Number of MyResources reconciled in parallel",
+		func(c *Config) flag.Value { return (*intValue)(&c.Workers) }},
+	{"max-retries", "Number of retries of a failed reconcile before giving up",
+		func(c *Config) flag.Value { return (*intValue)(&c.MaxRetries) }},
+	{"metrics-bind-address", "Address serving the Prometheus metrics on /metrics, empty disables it",
+		func(c *Config) flag.Value { return (*stringValue)(&c.MetricsBindAddress) }},
+	{"health-probe-bind-address", "Address serving the /healthz and /readyz probes, empty disables them",
+		func(c *Config) flag.Value { return (*stringValue)(&c.HealthProbeBindAddress) }},
+	{"webhook-bind-address", "Address serving the webhooks of the CRD over TLS, empty disables them",
+		func(c *Config) flag.Value { return (*stringValue)(&c.WebhookBindAddress) }},
+	{"webhook-tls-cert-file", "PEM file of the certificate served by the webhooks",
+		func(c *Config) flag.Value { return (*stringValue)(&c.WebhookCertFile) }},
+	{"webhook-tls-key-file", "PEM file of the private key of the webhook certificate",
+		func(c *Config) flag.Value { return (*stringValue)(&c.WebhookKeyFile) }},
+	{"cache-sync-timeout", "How long to wait for the informers to sync before exiting, 0 waits forever",
+		func(c *Config) flag.Value { return (*durationValue)(&c.CacheSyncTimeout) }},
+	{"worker-stall-timeout", "How long a single reconcile may take before the liveness probe fails",
+		func(c *Config) flag.Value { return (*durationValue)(&c.WorkerStallTimeout) }},
+	{"rate-limiter-base-delay", "Delay of the first retry of a failed reconcile, doubled on every retry",
+		func(c *Config) flag.Value { return (*durationValue)(&c.RateLimiter.BaseDelay) }},
+	{"rate-limiter-max-delay", "Maximum delay between the retries of a failed reconcile",
+		func(c *Config) flag.Value { return (*durationValue)(&c.RateLimiter.MaxDelay) }},
+	{"rate-limiter-qps", "Overall reconciles per second of requeued MyResources",
+		func(c *Config) flag.Value { return (*float64Value)(&c.RateLimiter.QPS) }},
+	{"rate-limiter-burst", "Overall burst of reconciles of requeued MyResources",
+		func(c *Config) flag.Value { return (*intValue)(&c.RateLimiter.Burst) }},
+	{"leader-elect", "Elect a leader among the controller replicas before processing resources",
+		func(c *Config) flag.Value { return (*boolValue)(&c.LeaderElection.Enabled) }},
+	{"leader-elect-resource-lock", "Type of the leader election lock, leases or configmaps",
+		func(c *Config) flag.Value { return (*stringValue)(&c.LeaderElection.ResourceLock) }},
+	{"leader-elect-namespace", "Namespace of the leader election lock",
+		func(c *Config) flag.Value { return (*stringValue)(&c.LeaderElection.Namespace) }},
+	{"leader-elect-name", "Name of the leader election lock",
+		func(c *Config) flag.Value { return (*stringValue)(&c.LeaderElection.Name) }},
+	{"leader-elect-lease-duration", "Duration non-leaders wait before they force acquire the leadership",
+		func(c *Config) flag.Value { return (*durationValue)(&c.LeaderElection.LeaseDuration) }},
+	{"leader-elect-renew-deadline", "Duration the leader retries refreshing the leadership before giving up",
+		func(c *Config) flag.Value { return (*durationValue)(&c.LeaderElection.RenewDeadline) }},
+	{"leader-elect-retry-period", "Duration candidates wait between tries of acquiring or renewing the leadership",
+		func(c *Config) flag.Value { return (*durationValue)(&c.LeaderElection.RetryPeriod) }},
+}
+
+// EnvName returns the environment variable of the option with the flag name
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// Loader builds the Config of a command. The values of the config file are
+// overridden by environment variables, which are overridden by flags
+type Loader struct {
+	flagSet    *flag.FlagSet
+	flags      *Config
+	configFile string
+	// LookupEnv reads the environment, it is replaced in tests
+	LookupEnv func(key string) (string, bool)
+}
+
+// NewLoader registers the flag of the config file and a flag for every
+// option on the FlagSet
+func NewLoader(flagSet *flag.FlagSet) *Loader {
+	l := &Loader{
+		flagSet:   flagSet,
+		flags:     Default(),
+		LookupEnv: os.LookupEnv,
+	}
+	flagSet.StringVar(&l.configFile, configFileFlag, "",
+		fmt.Sprintf("Path of an optional YAML config file, the env var %s is used when it is not set", EnvName(configFileFlag)))
+	for _, o := range options {
+		flagSet.Var(o.value(l.flags), o.name, fmt.Sprintf("%s (env %s)", o.usage, EnvName(o.name)))
+	}
+	return l
+}
+
+// Load returns the validated Config once the FlagSet has been parsed
+func (l *Loader) Load() (*Config, error) {
+	config := Default()
+
+	path := l.configFile
+	if path == "" {
+		path, _ = l.LookupEnv(EnvName(configFileFlag))
+	}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: \n%v", err)
+		}
+		// unknown fields are most likely typos, so refuse them
+		if err := yaml.UnmarshalStrict(data, config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: \n%v", path, err)
+		}
+	}
+
+	for _, o := range options {
+		env := EnvName(o.name)
+		if value, ok := l.LookupEnv(env); ok {
+			if err := o.value(config).Set(value); err != nil {
+				return nil, fmt.Errorf("invalid value %q for env var %s: %v", value, env, err)
+			}
+		}
+	}
+
+	// only the flags given on the command line override the others
+	set := map[string]bool{}
+	l.flagSet.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, o := range options {
+		if set[o.name] {
+			o.value(config).Set(o.value(l.flags).String())
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+func (v *stringValue) String() string { return string(*v) }
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*v = intValue(i)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type float64Value float64
+
+func (v *float64Value) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*v = float64Value(f)
+	return nil
+}
+
+func (v *float64Value) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+// IsBoolFlag allows the flag without a value, like --leader-elect
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type durationValue metav1.Duration
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	v.Duration = d
+	return nil
+}
+
+func (v *durationValue) String() string { return v.Duration.String() }
@@ -0,0 +1,294 @@
+// Package crd generates the CustomResourceDefinition of MyResource from the
+// Go types of its API package. The structure of the schema is read from the
+// types by reflection, their comments become the descriptions and the
+// +kubebuilder markers in them the validations and defaults:
+//
+//	+kubebuilder:validation:Minimum=0, Maximum, MinLength, MaxLength, Pattern
+//	+kubebuilder:validation:Enum=A;B;C
+//	+kubebuilder:default=1
+//	+optional, +required (fields without omitempty are required)
+//	+crd:anyOfRequired=a;b (one of the fields must be set)
+//
+// The MyResource type of every version carries the markers of the CRD
+// itself, like +kubebuilder:resource, +kubebuilder:subresource:status,
+// +kubebuilder:printcolumn and +kubebuilder:storageversion on the version
+// the API server stores. The versions are converted by the webhook of the
+// controller.
+package crd
+
+//go:generate go run ./gen -apis ../pkg/apis/myresource -output myresource.yaml
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strconv"
+
+	"sigs.k8s.io/yaml"
+
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v2"
+	"k8s-controller-custom-resource/webhook"
+)
+
+// header is written above the generated CRD
+const header = `# Code generated by "go generate ./crd", DO NOT EDIT.
+#
+# The CustomResourceDefinition of MyResource. The schemas are generated from the
+# Go types in pkg/apis/myresource/*/types.go, edit them and their +kubebuilder
+# markers instead of this file.
+`
+
+// the Service in front of the controller which serves the conversion
+// webhook, the API server only calls it over TLS on port 443. It is not
+// the Service of the probes, the conversion must work before the
+// informers of the controller have synced
+const (
+	webhookServiceNamespace = "default"
+	webhookServiceName      = "myresource-webhook"
+	webhookServicePort      = 443
+)
+
+// versions are the served versions of MyResource, in the order of the CRD
+var versions = []struct {
+	name         string
+	resourceType reflect.Type
+}{
+	{v1.SchemeGroupVersion.Version, reflect.TypeOf(v1.MyResource{})},
+	{v2.SchemeGroupVersion.Version, reflect.TypeOf(v2.MyResource{})},
+}
+
+// CustomResourceDefinition is the part of the apiextensions.k8s.io/v1
+// CustomResourceDefinition written by the generator
+type CustomResourceDefinition struct {
+	APIVersion string                       `json:"apiVersion"`
+	Kind       string                       `json:"kind"`
+	Metadata   ObjectMeta                   `json:"metadata"`
+	Spec       CustomResourceDefinitionSpec `json:"spec"`
+}
+
+// ObjectMeta is the metadata of a CustomResourceDefinition
+type ObjectMeta struct {
+	Name string `json:"name"`
+}
+
+// CustomResourceDefinitionSpec describes the MyResource API
+type CustomResourceDefinitionSpec struct {
+	Group      string                    `json:"group"`
+	Names      Names                     `json:"names"`
+	Scope      string                    `json:"scope"`
+	Versions   []CustomResourceVersion   `json:"versions"`
+	Conversion *CustomResourceConversion `json:"conversion,omitempty"`
+}
+
+// Names are the names of the resource in the API
+type Names struct {
+	Kind     string `json:"kind"`
+	ListKind string `json:"listKind,omitempty"`
+	Plural   string `json:"plural"`
+	Singular string `json:"singular,omitempty"`
+}
+
+// CustomResourceVersion describes a version of the resource, exactly one
+// version is stored
+type CustomResourceVersion struct {
+	Name                     string                    `json:"name"`
+	Served                   bool                      `json:"served"`
+	Storage                  bool                      `json:"storage"`
+	Schema                   *CustomResourceValidation `json:"schema,omitempty"`
+	Subresources             *Subresources             `json:"subresources,omitempty"`
+	AdditionalPrinterColumns []PrinterColumn           `json:"additionalPrinterColumns,omitempty"`
+}
+
+// Subresources are the subresources served for the resource
+type Subresources struct {
+	Status *struct{} `json:"status,omitempty"`
+}
+
+// PrinterColumn is a column of kubectl get
+type PrinterColumn struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Format      string `json:"format,omitempty"`
+	Description string `json:"description,omitempty"`
+	Priority    int32  `json:"priority,omitempty"`
+	JSONPath    string `json:"jsonPath"`
+}
+
+// CustomResourceValidation holds the schema of the resource
+type CustomResourceValidation struct {
+	OpenAPIV3Schema *JSONSchemaProps `json:"openAPIV3Schema,omitempty"`
+}
+
+// CustomResourceConversion tells the API server how to convert between
+// the versions
+type CustomResourceConversion struct {
+	Strategy string             `json:"strategy"`
+	Webhook  *WebhookConversion `json:"webhook,omitempty"`
+}
+
+// WebhookConversion is the webhook converting between the versions
+type WebhookConversion struct {
+	ClientConfig             WebhookClientConfig `json:"clientConfig"`
+	ConversionReviewVersions []string            `json:"conversionReviewVersions"`
+}
+
+// WebhookClientConfig locates the webhook, the caBundle is added when
+// the CRD is installed since it depends on the certificate of the cluster
+type WebhookClientConfig struct {
+	Service  *ServiceReference `json:"service,omitempty"`
+	CABundle []byte            `json:"caBundle,omitempty"`
+}
+
+// ServiceReference is the Service in front of a webhook
+type ServiceReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Path      string `json:"path,omitempty"`
+	Port      int32  `json:"port,omitempty"`
+}
+
+// Generate returns the CRD of MyResource, apisDir is the directory with a
+// package for every version whose comments describe the types
+func Generate(apisDir string) (*CustomResourceDefinition, error) {
+	crd := &CustomResourceDefinition{
+		APIVersion: "apiextensions.k8s.io/v1",
+		Kind:       "CustomResourceDefinition",
+		Spec: CustomResourceDefinitionSpec{
+			Group: v1.SchemeGroupVersion.Group,
+			Scope: "Namespaced",
+		},
+	}
+
+	storage := ""
+	for _, version := range versions {
+		comments, err := parseComments(filepath.Join(apisDir, version.name))
+		if err != nil {
+			return nil, err
+		}
+		resourceComment := comments[version.resourceType.Name()]
+		crd.Spec.Names.Kind = version.resourceType.Name()
+		if err := generateNames(crd, resourceComment); err != nil {
+			return nil, err
+		}
+
+		generated, err := generateVersion(version.name, version.resourceType, comments)
+		if err != nil {
+			return nil, fmt.Errorf("version %s: %v", version.name, err)
+		}
+		if generated.Storage {
+			if storage != "" {
+				return nil, fmt.Errorf("versions %s and %s are both marked +kubebuilder:storageversion", storage, version.name)
+			}
+			storage = version.name
+		}
+		crd.Spec.Versions = append(crd.Spec.Versions, *generated)
+	}
+	if storage == "" {
+		if len(crd.Spec.Versions) > 1 {
+			return nil, fmt.Errorf("one version needs the +kubebuilder:storageversion marker")
+		}
+		crd.Spec.Versions[0].Storage = true
+	}
+	crd.Metadata.Name = crd.Spec.Names.Plural + "." + crd.Spec.Group
+
+	if len(crd.Spec.Versions) > 1 {
+		crd.Spec.Conversion = &CustomResourceConversion{
+			Strategy: "Webhook",
+			Webhook: &WebhookConversion{
+				ClientConfig: WebhookClientConfig{Service: &ServiceReference{
+					Namespace: webhookServiceNamespace,
+					Name:      webhookServiceName,
+					Path:      webhook.ConvertPath,
+					Port:      webhookServicePort,
+				}},
+				ConversionReviewVersions: []string{"v1", "v1beta1"},
+			},
+		}
+	}
+	return crd, nil
+}
+
+// generateNames reads the names of the resource from its
+// +kubebuilder:resource marker, every version has to use the same names
+func generateNames(crd *CustomResourceDefinition, resourceComment comment) error {
+	names, scope := crd.Spec.Names, crd.Spec.Scope
+	for _, value := range resourceComment.lookupArgs("kubebuilder:resource") {
+		args, err := parseArgs(value)
+		if err != nil {
+			return fmt.Errorf("invalid marker +kubebuilder:resource: %v", err)
+		}
+		if path, ok := args["path"]; ok {
+			names.Plural = path
+		}
+		if value, ok := args["scope"]; ok {
+			scope = value
+		}
+		if singular, ok := args["singular"]; ok {
+			names.Singular = singular
+		}
+	}
+	if names.Plural == "" {
+		return fmt.Errorf("%s needs a +kubebuilder:resource:path marker", names.Kind)
+	}
+	if crd.Spec.Names.Plural != "" && (names != crd.Spec.Names || scope != crd.Spec.Scope) {
+		return fmt.Errorf("the +kubebuilder:resource markers of the versions differ")
+	}
+	crd.Spec.Names, crd.Spec.Scope = names, scope
+	return nil
+}
+
+// generateVersion returns the version of the CRD described by the type
+func generateVersion(name string, resourceType reflect.Type, comments map[string]comment) (*CustomResourceVersion, error) {
+	resourceComment := comments[resourceType.Name()]
+	schema, err := newSchemaGenerator(resourceType.PkgPath(), comments).schema(resourceType)
+	if err != nil {
+		return nil, err
+	}
+	schema.Description = resourceComment.description
+	version := &CustomResourceVersion{
+		Name:    name,
+		Served:  true,
+		Storage: resourceComment.has("kubebuilder:storageversion"),
+		Schema:  &CustomResourceValidation{OpenAPIV3Schema: &schema},
+	}
+
+	if resourceComment.has("kubebuilder:subresource:status") {
+		version.Subresources = &Subresources{Status: &struct{}{}}
+	}
+	for _, value := range resourceComment.lookupArgs("kubebuilder:printcolumn") {
+		args, err := parseArgs(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid marker +kubebuilder:printcolumn: %v", err)
+		}
+		column := PrinterColumn{
+			Name:        args["name"],
+			Type:        args["type"],
+			Format:      args["format"],
+			Description: args["description"],
+			JSONPath:    args["JSONPath"],
+		}
+		if priority, ok := args["priority"]; ok {
+			value, err := strconv.ParseInt(priority, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid priority of the %s column: %v", column.Name, err)
+			}
+			column.Priority = int32(value)
+		}
+		version.AdditionalPrinterColumns = append(version.AdditionalPrinterColumns, column)
+	}
+	return version, nil
+}
+
+// Marshal returns the CRD as the YAML file checked in next to this package
+func Marshal(crd *CustomResourceDefinition) ([]byte, error) {
+	data, err := yaml.Marshal(crd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the CRD: \n%v", err)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.Write(data)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,135 @@
+package crd
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const apisDir = "../pkg/apis/myresource"
+
+func TestCheckedInCRDIsUpToDate(t *testing.T) {
+	generated, err := Generate(apisDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Marshal(generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkedIn, err := ioutil.ReadFile("myresource.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(data), string(checkedIn), "crd/myresource.yaml is out of date, run go generate ./crd")
+}
+
+func TestGenerateSchema(t *testing.T) {
+	generated, err := Generate(apisDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "v1", generated.Spec.Versions[0].Name)
+	schema := generated.Spec.Versions[0].Schema.OpenAPIV3Schema
+	assert.Equal(t, []string{"spec"}, schema.Required)
+
+	spec := schema.Properties["spec"]
+	assert.Equal(t, []JSONSchemaProps{{Required: []string{"image"}}, {Required: []string{"message"}}}, spec.AnyOf)
+	assert.Equal(t, "integer", spec.Properties["replicas"].Type)
+	assert.Equal(t, float64(0), *spec.Properties["replicas"].Minimum)
+	assert.Equal(t, float64(1), spec.Properties["replicas"].Default)
+	assert.Equal(t, "Delete", spec.Properties["deletionPolicy"].Default)
+	assert.Equal(t, []interface{}{"Delete", "Retain", "Orphan"}, spec.Properties["deletionPolicy"].Enum)
+	assert.Equal(t, []interface{}{"GET", "PUT", "POST", "DELETE", "PATCH"},
+		spec.Properties["http"].Properties["methods"].Items.Enum)
+	assert.Equal(t, []string{"host"}, spec.Properties["ingress"].Required)
+	assert.Contains(t, spec.Properties["message"].Description, "Deprecated:")
+
+	limits := spec.Properties["resources"].Properties["limits"].AdditionalProperties
+	assert.True(t, limits.IntOrString)
+	assert.Equal(t, "date-time",
+		schema.Properties["status"].Properties["conditions"].Items.Properties["lastTransitionTime"].Format)
+}
+
+// assertStructural checks the rules of a structural schema which pruning
+// and defaulting need: every node has a type, and the branches of anyOf only
+// hold validations
+func assertStructural(t *testing.T, path string, schema JSONSchemaProps) {
+	if schema.Type == "" && !schema.IntOrString {
+		t.Errorf("%s has no type", path)
+	}
+	for _, branch := range schema.AnyOf {
+		if !schema.IntOrString && (branch.Type != "" || branch.Description != "" || branch.Default != nil) {
+			t.Errorf("%s has an anyOf branch with a type, description or default", path)
+		}
+	}
+	for name, property := range schema.Properties {
+		assertStructural(t, path+"."+name, property)
+	}
+	if schema.Items != nil {
+		assertStructural(t, path+"[]", *schema.Items)
+	}
+	if schema.AdditionalProperties != nil {
+		assertStructural(t, path+"{}", *schema.AdditionalProperties)
+	}
+}
+
+func TestGenerateStructuralSchema(t *testing.T) {
+	generated, err := Generate(apisDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range generated.Spec.Versions {
+		assertStructural(t, version.Name, *version.Schema.OpenAPIV3Schema)
+	}
+}
+
+func TestGenerateVersions(t *testing.T) {
+	generated, err := Generate(apisDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "apiextensions.k8s.io/v1", generated.APIVersion)
+	assert.Equal(t, "myresources.trstringer.com", generated.Metadata.Name)
+	assert.Equal(t, Names{Kind: "MyResource", Plural: "myresources"}, generated.Spec.Names)
+	if assert.Len(t, generated.Spec.Versions, 2) {
+		v1, v2 := generated.Spec.Versions[0], generated.Spec.Versions[1]
+		assert.Equal(t, []interface{}{"v1", true, false}, []interface{}{v1.Name, v1.Served, v1.Storage})
+		assert.Equal(t, []interface{}{"v2", true, true}, []interface{}{v2.Name, v2.Served, v2.Storage})
+		assert.NotNil(t, v2.Subresources.Status)
+		assert.Equal(t, ".spec.container.image", v2.AdditionalPrinterColumns[2].JSONPath)
+
+		spec := v2.Schema.OpenAPIV3Schema.Properties["spec"]
+		assert.Equal(t, []string{"container"}, spec.Required)
+		assert.Equal(t, []string{"image"}, spec.Properties["container"].Required)
+		assert.Equal(t, float64(8888), spec.Properties["container"].Properties["port"].Default)
+		assert.NotContains(t, spec.Properties, "message")
+	}
+	assert.Equal(t, &CustomResourceConversion{
+		Strategy: "Webhook",
+		Webhook: &WebhookConversion{
+			ClientConfig: WebhookClientConfig{Service: &ServiceReference{
+				Namespace: "default",
+				Name:      "myresource-webhook",
+				Path:      "/convert",
+				Port:      443,
+			}},
+			ConversionReviewVersions: []string{"v1", "v1beta1"},
+		},
+	}, generated.Spec.Conversion)
+}
+
+func TestParseArgs(t *testing.T) {
+	args, err := parseArgs("name=Ready,type=\"string\",JSONPath=`.status.conditions[?(@.type==\"Ready\")].status`,priority=1")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"name":     "Ready",
+		"type":     "string",
+		"JSONPath": `.status.conditions[?(@.type=="Ready")].status`,
+		"priority": "1",
+	}, args)
+
+	_, err = parseArgs("name=Ready,type")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,35 @@
+// Command gen writes the CustomResourceDefinition of MyResource generated
+// from its Go types, run it with go generate ./crd
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+
+	"k8s-controller-custom-resource/crd"
+)
+
+func main() {
+	apis := flag.String("apis", "pkg/apis/myresource", "directory with a package for every version of the API")
+	output := flag.String("output", "", "file to write the CRD to, stdout when empty")
+	flag.Parse()
+
+	generated, err := crd.Generate(*apis)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := crd.Marshal(generated)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := ioutil.WriteFile(*output, data, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,211 @@
+package crd
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// comment is the documentation of a type or a field of the API package,
+// its description and the markers starting with a +
+type comment struct {
+	description string
+	markers     []marker
+}
+
+// marker is a +name=value line of a comment, the value is empty for flags
+type marker struct {
+	name  string
+	value string
+}
+
+// lookup returns the values of the markers with the given name
+func (c comment) lookup(name string) []string {
+	var values []string
+	for _, m := range c.markers {
+		if m.name == name {
+			values = append(values, m.value)
+		}
+	}
+	return values
+}
+
+// lookupArgs returns the arguments of the markers with the given name
+// written like +name:key=value,key=value
+func (c comment) lookupArgs(name string) []string {
+	var args []string
+	for _, m := range c.markers {
+		if strings.HasPrefix(m.name, name+":") {
+			args = append(args, strings.TrimPrefix(m.name, name+":")+"="+m.value)
+		}
+	}
+	return args
+}
+
+func (c comment) has(name string) bool {
+	return len(c.lookup(name)) > 0
+}
+
+// parseComments reads the comments of the types in the Go files of dir,
+// keyed by the type name and by Type.Field for the fields
+func parseComments(dir string) (map[string]comment, error) {
+	fset := token.NewFileSet()
+	packages, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: \n%v", dir, err)
+	}
+
+	comments := map[string]comment{}
+	for _, pkg := range packages {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				genDecl, ok := decl.(*ast.GenDecl)
+				if !ok || genDecl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range genDecl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					doc := typeSpec.Doc
+					if doc == nil && len(genDecl.Specs) == 1 {
+						doc = genDecl.Doc
+					}
+					// markers may also be in a group of their own right
+					// above the documentation, like the +genclient ones
+					groups := []*ast.CommentGroup{precedingGroup(fset, file, doc), doc}
+					comments[typeSpec.Name.Name] = newComment(groups...)
+
+					structType, ok := typeSpec.Type.(*ast.StructType)
+					if !ok {
+						continue
+					}
+					for _, field := range structType.Fields.List {
+						for _, name := range fieldNames(field) {
+							comments[typeSpec.Name.Name+"."+name] = newComment(field.Doc)
+						}
+					}
+				}
+			}
+		}
+	}
+	return comments, nil
+}
+
+// fieldNames returns the names of a field, the type name for embedded ones
+func fieldNames(field *ast.Field) []string {
+	if len(field.Names) == 0 {
+		switch fieldType := field.Type.(type) {
+		case *ast.SelectorExpr:
+			return []string{fieldType.Sel.Name}
+		case *ast.Ident:
+			return []string{fieldType.Name}
+		}
+		return nil
+	}
+	var names []string
+	for _, name := range field.Names {
+		names = append(names, name.Name)
+	}
+	return names
+}
+
+// precedingGroup returns the comment group ending at most one blank line
+// above doc, or nil
+func precedingGroup(fset *token.FileSet, file *ast.File, doc *ast.CommentGroup) *ast.CommentGroup {
+	if doc == nil {
+		return nil
+	}
+	docLine := fset.Position(doc.Pos()).Line
+	for _, group := range file.Comments {
+		if group == doc {
+			continue
+		}
+		if endLine := fset.Position(group.End()).Line; endLine < docLine && endLine >= docLine-2 {
+			return group
+		}
+	}
+	return nil
+}
+
+// newComment splits the comment groups into the description, paragraphs
+// separated by an empty line, and the markers
+func newComment(groups ...*ast.CommentGroup) comment {
+	var c comment
+	var paragraphs []string
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			paragraphs = append(paragraphs, strings.Join(paragraph, " "))
+			paragraph = nil
+		}
+	}
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+		for _, line := range group.List {
+			text := strings.TrimSpace(strings.TrimPrefix(line.Text, "//"))
+			switch {
+			case strings.HasPrefix(text, "+"):
+				name, value := text[1:], ""
+				if i := strings.Index(name, "="); i >= 0 {
+					name, value = name[:i], name[i+1:]
+				}
+				c.markers = append(c.markers, marker{name: name, value: value})
+			case text == "":
+				flush()
+			default:
+				paragraph = append(paragraph, text)
+			}
+		}
+		flush()
+	}
+	c.description = strings.Join(paragraphs, "\n\n")
+	return c
+}
+
+// parseArgs parses the comma separated key=value arguments of a marker,
+// a value may be quoted with double quotes or backticks
+func parseArgs(args string) (map[string]string, error) {
+	values := map[string]string{}
+	for args != "" {
+		i := strings.Index(args, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("missing value of %q", args)
+		}
+		key, rest := args[:i], args[i+1:]
+		var value string
+		switch {
+		case strings.HasPrefix(rest, "`"):
+			end := strings.Index(rest[1:], "`")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated value of %s", key)
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		case strings.HasPrefix(rest, `"`):
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of %s: %v", key, err)
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+		default:
+			end := strings.Index(rest, ",")
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		values[key] = value
+		if rest != "" && !strings.HasPrefix(rest, ",") {
+			return nil, fmt.Errorf("expected a comma after the value of %s", key)
+		}
+		args = strings.TrimPrefix(rest, ",")
+	}
+	return values, nil
+}
@@ -0,0 +1,264 @@
+package crd
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// JSONSchemaProps is the part of the OpenAPI v3 schema of a CRD used by the
+// generated schemas, it marshals like the apiextensions type
+type JSONSchemaProps struct {
+	Description          string                     `json:"description,omitempty"`
+	Type                 string                     `json:"type,omitempty"`
+	Format               string                     `json:"format,omitempty"`
+	Enum                 []interface{}              `json:"enum,omitempty"`
+	Default              interface{}                `json:"default,omitempty"`
+	Minimum              *float64                   `json:"minimum,omitempty"`
+	Maximum              *float64                   `json:"maximum,omitempty"`
+	MinLength            *int64                     `json:"minLength,omitempty"`
+	MaxLength            *int64                     `json:"maxLength,omitempty"`
+	Pattern              string                     `json:"pattern,omitempty"`
+	Items                *JSONSchemaProps           `json:"items,omitempty"`
+	Properties           map[string]JSONSchemaProps `json:"properties,omitempty"`
+	AdditionalProperties *JSONSchemaProps           `json:"additionalProperties,omitempty"`
+	Required             []string                   `json:"required,omitempty"`
+	AnyOf                []JSONSchemaProps          `json:"anyOf,omitempty"`
+	IntOrString          bool                       `json:"x-kubernetes-int-or-string,omitempty"`
+}
+
+// types with their own JSON encoding
+var (
+	timeType        = reflect.TypeOf(meta_v1.Time{})
+	objectMetaType  = reflect.TypeOf(meta_v1.ObjectMeta{})
+	quantityType    = reflect.TypeOf(resource.Quantity{})
+	intOrStringType = reflect.TypeOf(intstr.IntOrString{})
+)
+
+// schemaGenerator builds the schema of Go types by reflection, the types
+// of the API package are described by their comments
+type schemaGenerator struct {
+	pkgPath  string
+	comments map[string]comment
+	// visiting guards against recursive types
+	visiting map[reflect.Type]bool
+}
+
+func newSchemaGenerator(pkgPath string, comments map[string]comment) *schemaGenerator {
+	return &schemaGenerator{pkgPath: pkgPath, comments: comments, visiting: map[reflect.Type]bool{}}
+}
+
+// typeComment returns the comment of a named type of the API package
+func (g *schemaGenerator) typeComment(t reflect.Type) (comment, bool) {
+	if t.PkgPath() != g.pkgPath {
+		return comment{}, false
+	}
+	c, ok := g.comments[t.Name()]
+	return c, ok
+}
+
+// schema returns the schema of the type
+func (g *schemaGenerator) schema(t reflect.Type) (JSONSchemaProps, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return JSONSchemaProps{Type: "string", Format: "date-time"}, nil
+	case objectMetaType:
+		return JSONSchemaProps{Type: "object"}, nil
+	case quantityType, intOrStringType:
+		return JSONSchemaProps{
+			IntOrString: true,
+			AnyOf:       []JSONSchemaProps{{Type: "integer"}, {Type: "string"}},
+		}, nil
+	}
+
+	var schema JSONSchemaProps
+	switch t.Kind() {
+	case reflect.String:
+		schema.Type = "string"
+	case reflect.Bool:
+		schema.Type = "boolean"
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		schema.Type, schema.Format = "integer", "int32"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		schema.Type, schema.Format = "integer", "int64"
+	case reflect.Float32, reflect.Float64:
+		schema.Type = "number"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			schema.Type, schema.Format = "string", "byte"
+			break
+		}
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return schema, err
+		}
+		schema.Type, schema.Items = "array", &items
+	case reflect.Map:
+		values, err := g.schema(t.Elem())
+		if err != nil {
+			return schema, err
+		}
+		schema.Type, schema.AdditionalProperties = "object", &values
+	case reflect.Struct:
+		if g.visiting[t] {
+			return schema, fmt.Errorf("recursive type %s", t)
+		}
+		g.visiting[t] = true
+		defer delete(g.visiting, t)
+		schema.Type = "object"
+		if err := g.addFields(&schema, t); err != nil {
+			return schema, err
+		}
+	default:
+		return schema, fmt.Errorf("unsupported kind %s of %s", t.Kind(), t)
+	}
+
+	if c, ok := g.typeComment(t); ok {
+		if err := applyMarkers(&schema, c); err != nil {
+			return schema, fmt.Errorf("%s: %v", t.Name(), err)
+		}
+	}
+	return schema, nil
+}
+
+// addFields adds the JSON fields of the struct to the properties of the
+// schema, inlined structs add their fields
+func (g *schemaGenerator) addFields(schema *JSONSchemaProps, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		name, options := tag[0], tag[1:]
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			if err := g.addFields(schema, field.Type); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property, err := g.schema(field.Type)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", t.Name(), field.Name, err)
+		}
+		fieldComment := comment{}
+		if t.PkgPath() == g.pkgPath {
+			fieldComment = g.comments[t.Name()+"."+field.Name]
+		}
+		switch c, ok := g.typeComment(field.Type); {
+		case field.Type == objectMetaType:
+			// the API server owns the schema of the metadata
+		case fieldComment.description != "":
+			property.Description = fieldComment.description
+		case ok:
+			property.Description = c.description
+		}
+		if err := applyMarkers(&property, fieldComment); err != nil {
+			return fmt.Errorf("%s.%s: %v", t.Name(), field.Name, err)
+		}
+		if schema.Properties == nil {
+			schema.Properties = map[string]JSONSchemaProps{}
+		}
+		schema.Properties[name] = property
+
+		omitempty := false
+		for _, option := range options {
+			omitempty = omitempty || option == "omitempty"
+		}
+		// like the Kubernetes API conventions, fields without omitempty
+		// are required unless they are marked as optional
+		if fieldComment.has("required") || !omitempty && !fieldComment.has("optional") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return nil
+}
+
+// applyMarkers sets the validations and the default of the markers of the
+// comment, the markers of other tools are skipped
+func applyMarkers(schema *JSONSchemaProps, c comment) error {
+	for _, m := range c.markers {
+		var err error
+		switch m.name {
+		case "kubebuilder:validation:Minimum":
+			schema.Minimum, err = parseFloat(m.value)
+		case "kubebuilder:validation:Maximum":
+			schema.Maximum, err = parseFloat(m.value)
+		case "kubebuilder:validation:MinLength":
+			schema.MinLength, err = parseInt(m.value)
+		case "kubebuilder:validation:MaxLength":
+			schema.MaxLength, err = parseInt(m.value)
+		case "kubebuilder:validation:Pattern":
+			schema.Pattern = m.value
+		case "kubebuilder:validation:Enum":
+			schema.Enum = nil
+			for _, value := range strings.Split(m.value, ";") {
+				schema.Enum = append(schema.Enum, parseValue(schema.Type, value))
+			}
+		case "kubebuilder:default":
+			schema.Default = parseValue(schema.Type, m.value)
+		case "crd:anyOfRequired":
+			// one of the fields must be set, like an old and a new name
+			schema.AnyOf = nil
+			for _, name := range strings.Split(m.value, ";") {
+				schema.AnyOf = append(schema.AnyOf, JSONSchemaProps{Required: []string{name}})
+			}
+		default:
+			if strings.HasPrefix(m.name, "kubebuilder:validation:") || strings.HasPrefix(m.name, "crd:") {
+				return fmt.Errorf("unknown marker +%s", m.name)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("invalid marker +%s=%s: %v", m.name, m.value, err)
+		}
+	}
+	return nil
+}
+
+func parseFloat(value string) (*float64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func parseInt(value string) (*int64, error) {
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// parseValue returns the value of an enum or a default, strings may be
+// quoted and other values are JSON
+func parseValue(schemaType, value string) interface{} {
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	if schemaType == "string" {
+		return value
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return value
+	}
+	return parsed
+}
@@ -0,0 +1,220 @@
+package election
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/leaderelection"
+	rl "k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+)
+
+// Config holds the settings of the leader election
+type Config struct {
+	// LockType is either "leases" or "configmaps"
+	LockType      string
+	LockNamespace string
+	LockName      string
+	// Identity of this candidate, defaults to the hostname with a random suffix
+	Identity string
+
+	// LeaseDuration is how long non-leaders wait before they force
+	// acquire the leadership, it must be greater than RenewDeadline
+	LeaseDuration time.Duration
+	// RenewDeadline is how long the leader retries refreshing the
+	// leadership before giving up
+	RenewDeadline time.Duration
+	// RetryPeriod is how long candidates wait between tries
+	RetryPeriod time.Duration
+
+	// EventRecorder records the leadership changes on the lock object,
+	// defaults to a recorder which only logs them
+	EventRecorder record.EventRecorder
+	// OnLostLeadership is called when the leadership is lost while the
+	// context is still active, once the controller has stopped
+	OnLostLeadership func()
+}
+
+// NewLock creates the resource lock of the configured type
+func NewLock(client kubernetes.Interface, config Config) (rl.Interface, error) {
+	lockConfig := rl.ResourceLockConfig{
+		Identity:      config.Identity,
+		EventRecorder: config.EventRecorder,
+	}
+	switch config.LockType {
+	case LeasesResourceLock:
+		return &releasableLock{Interface: &LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{
+				Namespace: config.LockNamespace,
+				Name:      config.LockName,
+			},
+			Client:     client.CoordinationV1beta1(),
+			LockConfig: lockConfig,
+		}}, nil
+	case rl.ConfigMapsResourceLock:
+		lock, err := rl.New(config.LockType, config.LockNamespace, config.LockName, client.CoreV1(), lockConfig)
+		if err != nil {
+			return nil, err
+		}
+		return &releasableLock{Interface: lock}, nil
+	default:
+		return nil, fmt.Errorf("invalid lock type %s, must be %s or %s",
+			config.LockType, LeasesResourceLock, rl.ConfigMapsResourceLock)
+	}
+}
+
+// Run blocks until ctx is done, calling run with a stop channel while this
+// candidate holds the leadership. When ctx is done the controller is stopped
+// and the lock is released so that another replica can take over without
+// waiting for the lease to expire
+func Run(ctx context.Context, client kubernetes.Interface, config Config, run func(stopCh <-chan struct{})) error {
+	if config.Identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("failed to get hostname for the leader election identity:\n%v", err)
+		}
+		config.Identity = hostname + "_" + rand.String(5)
+	}
+	if config.EventRecorder == nil {
+		broadcaster := record.NewBroadcaster()
+		broadcaster.StartLogging(log.Infof)
+		config.EventRecorder = broadcaster.NewRecorder(scheme.Scheme, apiv1.EventSource{Component: config.LockName})
+	}
+
+	lock, err := NewLock(client, config)
+	if err != nil {
+		return err
+	}
+
+	// OnStartedLeading runs in its own goroutine, so track whether it
+	// started to wait for the controller to stop before releasing the lock
+	var mu sync.Mutex
+	running, stopped := false, false
+	runDone := make(chan struct{})
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: config.LeaseDuration,
+		RenewDeadline: config.RenewDeadline,
+		RetryPeriod:   config.RetryPeriod,
+		Name:          config.LockName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				mu.Lock()
+				if stopped {
+					mu.Unlock()
+					return
+				}
+				running = true
+				mu.Unlock()
+				defer close(runDone)
+
+				log.Infof("Election.Run: %s started leading", config.Identity)
+				run(leaderCtx.Done())
+			},
+			OnStoppedLeading: func() {
+				log.Infof("Election.Run: %s stopped leading", config.Identity)
+			},
+			OnNewLeader: func(identity string) {
+				log.Infof("Election.Run: new leader elected: %s", identity)
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("invalid leader election config:\n%v", err)
+	}
+
+	log.Infof("Election.Run: %s is waiting for lock %s", config.Identity, lock.Describe())
+	elector.Run(ctx)
+
+	mu.Lock()
+	stopped = true
+	wasRunning := running
+	mu.Unlock()
+	if wasRunning {
+		<-runDone
+	}
+
+	if ctx.Err() == nil {
+		log.Errorf("Election.Run: %s lost the leadership", config.Identity)
+		if config.OnLostLeadership != nil {
+			config.OnLostLeadership()
+		}
+		return nil
+	}
+
+	// the elector may leave a renewal running in the background when it
+	// stops, so release through a lock that is not shared with it
+	releaseLock, err := NewLock(client, config)
+	if err != nil {
+		return err
+	}
+	return release(releaseLock)
+}
+
+// release clears the holder of the lock if this candidate still holds it
+func release(lock rl.Interface) error {
+	record, err := lock.Get()
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get lock %s for release:\n%v", lock.Describe(), err)
+	}
+	if record.HolderIdentity != lock.Identity() {
+		return nil
+	}
+
+	now := metav1.Now()
+	if err := lock.Update(rl.LeaderElectionRecord{
+		LeaseDurationSeconds: 1,
+		AcquireTime:          now,
+		RenewTime:            now,
+		LeaderTransitions:    record.LeaderTransitions,
+	}); err != nil {
+		return fmt.Errorf("failed to release lock %s:\n%v", lock.Describe(), err)
+	}
+	log.Infof("Election.Run: released lock %s", lock.Describe())
+	return nil
+}
+
+// releasableLock reports a lock without holder as not found. The elector of
+// the client-go version we build against waits a full lease duration after
+// any change of the record, even when the holder released it, but it takes
+// a missing lock right away through Create, which is turned into an Update
+type releasableLock struct {
+	rl.Interface
+	// released is the last record read if it had no holder
+	released *rl.LeaderElectionRecord
+}
+
+// Get returns the election record, or not found if it has been released
+func (l *releasableLock) Get() (*rl.LeaderElectionRecord, error) {
+	record, err := l.Interface.Get()
+	l.released = nil
+	if err == nil && record.HolderIdentity == "" {
+		l.released = record
+		return nil, errors.NewNotFound(schema.GroupResource{}, l.Describe())
+	}
+	return record, err
+}
+
+// Create creates the election record, or takes over a released one
+func (l *releasableLock) Create(ler rl.LeaderElectionRecord) error {
+	if l.released != nil {
+		ler.LeaderTransitions = l.released.LeaderTransitions + 1
+		return l.Interface.Update(ler)
+	}
+	return l.Interface.Create(ler)
+}
@@ -0,0 +1,136 @@
+package election
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+func newTestConfig(lockType, identity string) Config {
+	return Config{
+		LockType:      lockType,
+		LockNamespace: "default",
+		LockName:      "myresource-controller",
+		Identity:      identity,
+		LeaseDuration: 10 * time.Second,
+		RenewDeadline: 500 * time.Millisecond,
+		RetryPeriod:   100 * time.Millisecond,
+		EventRecorder: record.NewFakeRecorder(100),
+	}
+}
+
+// candidate runs one leader election participant in the background
+type candidate struct {
+	cancel  context.CancelFunc
+	done    chan error
+	mu      sync.Mutex
+	leading bool
+}
+
+func startCandidate(client *fake.Clientset, config Config) *candidate {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &candidate{cancel: cancel, done: make(chan error, 1)}
+	go func() {
+		c.done <- Run(ctx, client, config, func(stopCh <-chan struct{}) {
+			c.setLeading(true)
+			<-stopCh
+			c.setLeading(false)
+		})
+	}()
+	return c
+}
+
+func (c *candidate) setLeading(leading bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.leading = leading
+}
+
+func (c *candidate) isLeading() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.leading
+}
+
+func waitFor(condition func() bool) error {
+	return wait.PollImmediate(10*time.Millisecond, 3*time.Second, func() (bool, error) {
+		return condition(), nil
+	})
+}
+
+func TestRunReleasesLockOnShutdown(t *testing.T) {
+	for _, lockType := range []string{LeasesResourceLock, "configmaps"} {
+		client := fake.NewSimpleClientset()
+		first := startCandidate(client, newTestConfig(lockType, "first"))
+		assert.Nil(t, waitFor(first.isLeading), lockType)
+
+		second := startCandidate(client, newTestConfig(lockType, "second"))
+		time.Sleep(300 * time.Millisecond)
+		assert.False(t, second.isLeading(), lockType)
+
+		// the lease is far from expired, so the second candidate can only
+		// take over this quickly because the first one released it
+		first.cancel()
+		assert.Nil(t, <-first.done, lockType)
+		assert.False(t, first.isLeading(), lockType)
+		assert.Nil(t, waitFor(second.isLeading), lockType)
+
+		second.cancel()
+		assert.Nil(t, <-second.done, lockType)
+		lock, err := NewLock(client, newTestConfig(lockType, "third"))
+		assert.Nil(t, err)
+		_, err = lock.Get()
+		assert.NotNil(t, err, "lock should be released")
+	}
+}
+
+func TestRunCallsOnLostLeadership(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	// reactors can not be added while the fake is in use, so install
+	// one up front which fails the renewals once failing is set
+	var failing int32
+	client.PrependReactor("update", "leases", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return true, nil, errors.New("apiserver unavailable")
+		}
+		return false, nil, nil
+	})
+	config := newTestConfig(LeasesResourceLock, "first")
+	lost := make(chan struct{})
+	config.OnLostLeadership = func() { close(lost) }
+
+	c := startCandidate(client, config)
+	assert.Nil(t, waitFor(c.isLeading))
+	atomic.StoreInt32(&failing, 1)
+
+	select {
+	case <-lost:
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnLostLeadership was not called")
+	}
+	assert.False(t, c.isLeading())
+	assert.Nil(t, <-c.done)
+	c.cancel()
+}
+
+func TestNewLockInvalidType(t *testing.T) {
+	_, err := NewLock(fake.NewSimpleClientset(), newTestConfig("endpoints", "first"))
+	assert.NotNil(t, err)
+}
+
+func TestRunInvalidDurations(t *testing.T) {
+	config := newTestConfig(LeasesResourceLock, "first")
+	config.LeaseDuration = config.RenewDeadline
+	err := Run(context.Background(), fake.NewSimpleClientset(), config, func(stopCh <-chan struct{}) {})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,142 @@
+package election
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coordinationclient "k8s.io/client-go/kubernetes/typed/coordination/v1beta1"
+	rl "k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// LeasesResourceLock is the lock type which keeps the election record in a
+// coordination.k8s.io Lease, the client-go version we build against only
+// ships the Endpoints and ConfigMap locks
+const LeasesResourceLock = "leases"
+
+// LeaseLock implements the resourcelock.Interface on top of a Lease object
+type LeaseLock struct {
+	// LeaseMeta should contain a Name and a Namespace of a
+	// Lease object that the LeaderElector will attempt to lead.
+	LeaseMeta  metav1.ObjectMeta
+	Client     coordinationclient.LeasesGetter
+	LockConfig rl.ResourceLockConfig
+
+	// leaseLock guards lease, the elector may still renew in another
+	// goroutine while it records an event
+	leaseLock sync.Mutex
+	lease     *coordinationv1beta1.Lease
+}
+
+func (ll *LeaseLock) getLease() *coordinationv1beta1.Lease {
+	ll.leaseLock.Lock()
+	defer ll.leaseLock.Unlock()
+	return ll.lease
+}
+
+func (ll *LeaseLock) setLease(lease *coordinationv1beta1.Lease) {
+	ll.leaseLock.Lock()
+	defer ll.leaseLock.Unlock()
+	ll.lease = lease
+}
+
+// Get returns the election record from the Lease spec
+func (ll *LeaseLock) Get() (*rl.LeaderElectionRecord, error) {
+	lease, err := ll.Client.Leases(ll.LeaseMeta.Namespace).Get(ll.LeaseMeta.Name, metav1.GetOptions{})
+	if err != nil {
+		ll.setLease(nil)
+		return nil, err
+	}
+	ll.setLease(lease)
+	return leaseSpecToLeaderElectionRecord(&lease.Spec), nil
+}
+
+// Create attempts to create a Lease holding the LeaderElectionRecord
+func (ll *LeaseLock) Create(ler rl.LeaderElectionRecord) error {
+	lease, err := ll.Client.Leases(ll.LeaseMeta.Namespace).Create(&coordinationv1beta1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ll.LeaseMeta.Name,
+			Namespace: ll.LeaseMeta.Namespace,
+		},
+		Spec: leaderElectionRecordToLeaseSpec(&ler),
+	})
+	if err != nil {
+		ll.setLease(nil)
+		return err
+	}
+	ll.setLease(lease)
+	return nil
+}
+
+// Update will update the spec of the existing Lease
+func (ll *LeaseLock) Update(ler rl.LeaderElectionRecord) error {
+	current := ll.getLease()
+	if current == nil {
+		return errors.New("lease not initialized, call get or create first")
+	}
+	lease := current.DeepCopy()
+	lease.Spec = leaderElectionRecordToLeaseSpec(&ler)
+	lease, err := ll.Client.Leases(ll.LeaseMeta.Namespace).Update(lease)
+	if err != nil {
+		return err
+	}
+	ll.setLease(lease)
+	return nil
+}
+
+// RecordEvent in leader election while adding meta-data
+func (ll *LeaseLock) RecordEvent(s string) {
+	lease := ll.getLease()
+	if ll.LockConfig.EventRecorder == nil || lease == nil {
+		return
+	}
+	events := fmt.Sprintf("%v %v", ll.LockConfig.Identity, s)
+	ll.LockConfig.EventRecorder.Eventf(&coordinationv1beta1.Lease{ObjectMeta: lease.ObjectMeta}, apiv1.EventTypeNormal, "LeaderElection", events)
+}
+
+// Describe is used to convert details on current resource lock
+// into a string
+func (ll *LeaseLock) Describe() string {
+	return fmt.Sprintf("%v/%v", ll.LeaseMeta.Namespace, ll.LeaseMeta.Name)
+}
+
+// Identity returns the Identity of the lock
+func (ll *LeaseLock) Identity() string {
+	return ll.LockConfig.Identity
+}
+
+func leaseSpecToLeaderElectionRecord(spec *coordinationv1beta1.LeaseSpec) *rl.LeaderElectionRecord {
+	record := &rl.LeaderElectionRecord{}
+	if spec.HolderIdentity != nil {
+		record.HolderIdentity = *spec.HolderIdentity
+	}
+	if spec.LeaseDurationSeconds != nil {
+		record.LeaseDurationSeconds = int(*spec.LeaseDurationSeconds)
+	}
+	if spec.LeaseTransitions != nil {
+		record.LeaderTransitions = int(*spec.LeaseTransitions)
+	}
+	if spec.AcquireTime != nil {
+		record.AcquireTime = metav1.Time{Time: spec.AcquireTime.Time}
+	}
+	if spec.RenewTime != nil {
+		record.RenewTime = metav1.Time{Time: spec.RenewTime.Time}
+	}
+	return record
+}
+
+func leaderElectionRecordToLeaseSpec(ler *rl.LeaderElectionRecord) coordinationv1beta1.LeaseSpec {
+	holderIdentity := ler.HolderIdentity
+	leaseDurationSeconds := int32(ler.LeaseDurationSeconds)
+	leaseTransitions := int32(ler.LeaderTransitions)
+	return coordinationv1beta1.LeaseSpec{
+		HolderIdentity:       &holderIdentity,
+		LeaseDurationSeconds: &leaseDurationSeconds,
+		AcquireTime:          &metav1.MicroTime{Time: ler.AcquireTime.Time},
+		RenewTime:            &metav1.MicroTime{Time: ler.RenewTime.Time},
+		LeaseTransitions:     &leaseTransitions,
+	}
+}
@@ -0,0 +1,50 @@
+package health
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+)
+
+// Check returns an error when the part of the controller it checks is not
+// healthy
+type Check func() error
+
+// NamedCheck is a Check with the name it is reported under
+type NamedCheck struct {
+	Name  string
+	Check Check
+}
+
+// Handler runs every check on each request, it answers 200 when they all
+// pass and 500 with the failed checks otherwise. The result of every check
+// is listed with the verbose query parameter, like the API server does
+func Handler(checks ...NamedCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var out bytes.Buffer
+		failed := false
+		for _, check := range checks {
+			if err := check.Check(); err != nil {
+				failed = true
+				fmt.Fprintf(&out, "[-]%s failed: %v\n", check.Name, err)
+			} else {
+				fmt.Fprintf(&out, "[+]%s ok\n", check.Name)
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if failed {
+			w.WriteHeader(http.StatusInternalServerError)
+			out.WriteString("check failed\n")
+			out.WriteTo(w)
+			return
+		}
+		if _, verbose := r.URL.Query()["verbose"]; verbose {
+			out.WriteString("check passed\n")
+			out.WriteTo(w)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	})
+}
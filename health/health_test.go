@@ -0,0 +1,42 @@
+package health
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func get(t *testing.T, handler http.Handler, url string) (int, string) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+	body, err := ioutil.ReadAll(recorder.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recorder.Code, string(body)
+}
+
+func TestHandler(t *testing.T) {
+	var informersErr error
+	handler := Handler(
+		NamedCheck{"ping", func() error { return nil }},
+		NamedCheck{"informers", func() error { return informersErr }},
+	)
+
+	code, body := get(t, handler, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", body)
+
+	code, body = get(t, handler, "/readyz?verbose")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "[+]ping ok\n[+]informers ok\ncheck passed\n", body)
+
+	informersErr = errors.New("informers have not synced")
+	code, body = get(t, handler, "/readyz")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, "[+]ping ok\n[-]informers failed: informers have not synced\ncheck failed\n", body)
+}
@@ -0,0 +1,113 @@
+package jsonpatch
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Operation is one operation of a JSON patch
+type Operation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// EscapePathKey escapes a key for a JSON pointer, label keys contain slashes
+func EscapePathKey(key string) string {
+	return strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+}
+
+// isSubset returns whether every field set in desired has the same value in
+// live. Fields only set in live, like the defaults added by the API server,
+// do not matter, but lists must have the same length
+func isSubset(desired, live interface{}) bool {
+	switch desiredValue := desired.(type) {
+	case map[string]interface{}:
+		liveValue, ok := live.(map[string]interface{})
+		if !ok {
+			return live == nil && len(desiredValue) == 0
+		}
+		for key, value := range desiredValue {
+			if !isSubset(value, liveValue[key]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		liveValue, ok := live.([]interface{})
+		if !ok {
+			return live == nil && len(desiredValue) == 0
+		}
+		if len(desiredValue) != len(liveValue) {
+			return false
+		}
+		for i := range desiredValue {
+			if !isSubset(desiredValue[i], liveValue[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(desired, live)
+	}
+}
+
+// Diff returns the JSON patch operations turning live into desired below
+// the given path, fields missing in desired are removed. Both objects are in
+// the unstructured form
+func Diff(path string, desired, live map[string]interface{}) []Operation {
+	return diff(path, desired, live, true)
+}
+
+// DiffSubset returns the JSON patch operations which set the fields of
+// desired that differ from live below the given path. Fields only set in
+// live are kept, like the defaults added by the API server
+func DiffSubset(path string, desired, live map[string]interface{}) []Operation {
+	return diff(path, desired, live, false)
+}
+
+// diff compares the objects field by field and replaces lists as a whole.
+// Empty objects missing in live are skipped, they only come from
+// marshalling structs
+func diff(path string, desired, live map[string]interface{}, remove bool) []Operation {
+	keys := make([]string, 0, len(desired)+len(live))
+	for key := range desired {
+		keys = append(keys, key)
+	}
+	if remove {
+		for key := range live {
+			if _, ok := desired[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+	// sorted so that the patch is stable
+	sort.Strings(keys)
+
+	var operations []Operation
+	for _, key := range keys {
+		keyPath := path + "/" + EscapePathKey(key)
+		desiredValue, inDesired := desired[key]
+		liveValue, inLive := live[key]
+		if !inDesired {
+			operations = append(operations, Operation{Op: "remove", Path: keyPath})
+			continue
+		}
+		if remove && reflect.DeepEqual(desiredValue, liveValue) || !remove && isSubset(desiredValue, liveValue) {
+			continue
+		}
+		desiredMap, desiredIsMap := desiredValue.(map[string]interface{})
+		liveMap, liveIsMap := liveValue.(map[string]interface{})
+		switch {
+		case desiredIsMap && liveIsMap:
+			operations = append(operations, diff(keyPath, desiredMap, liveMap, remove)...)
+		case inLive:
+			operations = append(operations, Operation{Op: "replace", Path: keyPath, Value: desiredValue})
+		case desiredIsMap && len(desiredMap) == 0:
+		default:
+			operations = append(operations, Operation{Op: "add", Path: keyPath, Value: desiredValue})
+		}
+	}
+	return operations
+}
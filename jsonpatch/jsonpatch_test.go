@@ -0,0 +1,71 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSubsetIgnoresLiveOnlyFields(t *testing.T) {
+	desired := map[string]interface{}{
+		"replicas":   int64(1),
+		"containers": []interface{}{map[string]interface{}{"name": "web", "image": "nginx"}},
+	}
+	live := map[string]interface{}{
+		"replicas":             int64(1),
+		"revisionHistoryLimit": int64(10),
+		"containers": []interface{}{map[string]interface{}{
+			"name":            "web",
+			"image":           "nginx",
+			"imagePullPolicy": "Always",
+		}},
+	}
+	assert.True(t, isSubset(desired, live))
+	assert.True(t, isSubset(map[string]interface{}{}, nil))
+
+	live["containers"] = append(live["containers"].([]interface{}), map[string]interface{}{"name": "sidecar"})
+	assert.False(t, isSubset(desired, live))
+}
+
+// objects returns a desired and a live object which differ in every way
+func objects() (map[string]interface{}, map[string]interface{}) {
+	desired := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{"app.kubernetes.io/name": "myresource", "team": "a"},
+		},
+		"spec": map[string]interface{}{"replicas": int64(1), "paused": false, "template": map[string]interface{}{}},
+	}
+	live := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{"app.kubernetes.io/name": "other", "extra": "kept"},
+		},
+		"spec": map[string]interface{}{"replicas": int64(3), "paused": false},
+	}
+	return desired, live
+}
+
+func TestDiffSubset(t *testing.T) {
+	desired, live := objects()
+	assert.Equal(t, []Operation{
+		{Op: "replace", Path: "/metadata/labels/app.kubernetes.io~1name", Value: "myresource"},
+		{Op: "add", Path: "/metadata/labels/team", Value: "a"},
+		{Op: "replace", Path: "/spec/replicas", Value: int64(1)},
+	}, DiffSubset("", desired, live))
+	assert.Empty(t, DiffSubset("", desired, desired))
+}
+
+func TestDiff(t *testing.T) {
+	desired, live := objects()
+	assert.Equal(t, []Operation{
+		{Op: "replace", Path: "/metadata/labels/app.kubernetes.io~1name", Value: "myresource"},
+		{Op: "remove", Path: "/metadata/labels/extra"},
+		{Op: "add", Path: "/metadata/labels/team", Value: "a"},
+		{Op: "replace", Path: "/spec/replicas", Value: int64(1)},
+	}, Diff("", desired, live))
+	assert.Empty(t, Diff("", desired, desired))
+}
+
+func TestEscapePathKey(t *testing.T) {
+	assert.Equal(t, "app.kubernetes.io~1name", EscapePathKey("app.kubernetes.io/name"))
+	assert.Equal(t, "a~0b~1c", EscapePathKey("a~b/c"))
+}
@@ -3,8 +3,10 @@
 ROOT_PACKAGE="/Users/ken/WorkSpace/my-github/k8s-controller-custom-resource"
 # CUSTOM_RESOURCE_NAME :: the name of the custom resource that we're generating client code for
 CUSTOM_RESOURCE_NAME="myresource"
-# CUSTOM_RESOURCE_VERSION :: the version of the resource
+# CUSTOM_RESOURCE_VERSION :: the version of the resource that gets the client, informers and listers
 CUSTOM_RESOURCE_VERSION="v1"
+# CONVERTED_RESOURCE_VERSION :: the versions the API server converts to, they only get deepcopy
+CONVERTED_RESOURCE_VERSION="v2"
 
 # retrieve the code-generator scripts and bins
 go get -u k8s.io/code-generator/...
@@ -12,6 +14,7 @@ cd $GOPATH/pkg/mod/k8s.io/code-generator@v0.0.0-20181206115026-3a2206dd6a78
 
 # run the code-generator entrypoint script
 ./generate-groups.sh all "$ROOT_PACKAGE/pkg/client" "$ROOT_PACKAGE/pkg/apis" "$CUSTOM_RESOURCE_NAME:$CUSTOM_RESOURCE_VERSION"
+./generate-groups.sh deepcopy "$ROOT_PACKAGE/pkg/client" "$ROOT_PACKAGE/pkg/apis" "$CUSTOM_RESOURCE_NAME:$CONVERTED_RESOURCE_VERSION"
 
 # view the newly generated files
 tree $GOPATH/src/$ROOT_PACKAGE/pkg/client
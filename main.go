@@ -1,65 +1,244 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"syscall"
 
 	log "github.com/Sirupsen/logrus"
-	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"golang.org/x/time/rate"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	appslister_v1 "k8s.io/client-go/listers/apps/v1"
+	corelister_v1 "k8s.io/client-go/listers/core/v1"
+	extensionslister_v1beta1 "k8s.io/client-go/listers/extensions/v1beta1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
+	"k8s-controller-custom-resource/config"
+	"k8s-controller-custom-resource/election"
+	"k8s-controller-custom-resource/health"
+	"k8s-controller-custom-resource/metrics"
+	myresourcescheme "k8s-controller-custom-resource/pkg/client/clientset/versioned/scheme"
 	myresourceinformer_v1 "k8s-controller-custom-resource/pkg/client/informers/externalversions/myresource/v1"
+	myresourcelister_v1 "k8s-controller-custom-resource/pkg/client/listers/myresource/v1"
+	"k8s-controller-custom-resource/service"
 	"k8s-controller-custom-resource/util"
+	"k8s-controller-custom-resource/webhook"
 	"k8s-controller-custom-resource/worker"
 )
 
+// version of the controller, set at build time with
+// -ldflags "-X main.version=v1.2.3"
+var version = "devel"
+
+const usage = `Usage: %[1]s <command> [flags]
+
+Commands:
+  run       run the controller (the default when the first argument is a flag)
+  version   print the version
+  render    print the effective configuration as a YAML config file
+  validate  check the configuration and exit
+
+Every command except version accepts the flags below. A config file given with
+--config is overridden by MYRESOURCE_* env vars, which are overridden by flags.
+Run '%[1]s <command> -h' for the flags.
+`
+
 // main code path
 func main() {
+	os.Exit(runCommand(os.Args[1:]))
+}
+
+// runCommand runs the command of the arguments and returns the exit code
+func runCommand(args []string) int {
+	command := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "version":
+		fmt.Printf("myresource-controller %s (%s)\n", version, runtime.Version())
+		return 0
+	case "run", "render", "validate":
+	case "help":
+		fmt.Printf(usage, os.Args[0])
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", command)
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		return 2
+	}
+
+	flagSet := flag.NewFlagSet(command, flag.ContinueOnError)
+	loader := config.NewLoader(flagSet)
+	if err := flagSet.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if flagSet.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments %v\n", flagSet.Args())
+		return 2
+	}
+	cfg, err := loader.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch command {
+	case "render":
+		data, err := cfg.YAML()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		os.Stdout.Write(data)
+	case "validate":
+		fmt.Println("configuration is valid")
+	default:
+		run(cfg)
+	}
+	return 0
+}
+
+// newRateLimiter returns the rate limiter of the queue, the per item
+// exponential backoff and the overall bucket like the client-go default
+func newRateLimiter(cfg config.RateLimiterConfig) workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(cfg.BaseDelay.Duration, cfg.MaxDelay.Duration),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(cfg.QPS), cfg.Burst)},
+	)
+}
+
+// serve the handlers of the paths on the address until the returned
+// server is closed, the process exits when the address can't be used
+func serve(address string, handlers map[string]http.Handler) *http.Server {
+	return serveWith(address, handlers, (*http.Server).ListenAndServe)
+}
+
+// serveTLS is serve over TLS with the certificate and key files
+func serveTLS(address, certFile, keyFile string, handlers map[string]http.Handler) *http.Server {
+	return serveWith(address, handlers, func(server *http.Server) error {
+		return server.ListenAndServeTLS(certFile, keyFile)
+	})
+}
+
+func serveWith(address string, handlers map[string]http.Handler, listen func(*http.Server) error) *http.Server {
+	mux := http.NewServeMux()
+	for path, handler := range handlers {
+		mux.Handle(path, handler)
+	}
+	server := &http.Server{Addr: address, Handler: mux}
+	go func() {
+		log.Infof("Serving %d endpoints on %s", len(handlers), address)
+		if err := listen(server); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Error serving on %s:\n%v", address, err)
+		}
+	}()
+	return server
+}
+
+// run the controller until SIGTERM or SIGINT
+func run(cfg *config.Config) {
+	// register the metrics before the clients and the queue are created,
+	// they only report to the provider set at that time
+	metrics.Register()
+	if cfg.MetricsBindAddress != "" {
+		server := serve(cfg.MetricsBindAddress, map[string]http.Handler{"/metrics": metrics.Handler()})
+		defer server.Close()
+	}
+
 	// get the Kubernetes client for connectivity
-	client, myResourceClient := util.GetBothKubernetesClient()
+	client, myResourceClient := util.GetBothKubernetesClient(util.ConfigOptions{
+		Kubeconfig: cfg.Kubeconfig,
+		Context:    cfg.Context,
+		QPS:        float32(cfg.KubeAPIQPS),
+		Burst:      cfg.KubeAPIBurst,
+		UserAgent:  cfg.UserAgent,
+	})
+
+	// every replica serves the webhooks from the start, leader or not and
+	// ready or not, the informers of a v2 storage can't sync without the
+	// conversion. The node port check reads the Services of all namespaces
+	// from an informer of its own, the one of the controller only runs on
+	// the leader
+	if cfg.WebhookBindAddress != "" {
+		services := worker.NewServiceInformer(client, apiv1.NamespaceAll, cfg.ResyncPeriod.Duration)
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		go services.Run(stopCh)
+		server := serveTLS(cfg.WebhookBindAddress, cfg.WebhookCertFile, cfg.WebhookKeyFile, map[string]http.Handler{
+			webhook.ConvertPath: webhook.NewConversionHandler(),
+			webhook.ValidatePath: webhook.NewValidatingHandler(corelister_v1.NewServiceLister(services.GetIndexer()),
+				services.HasSynced),
+			webhook.MutatePath: webhook.NewMutatingHandler(),
+		})
+		defer server.Close()
+	}
 
 	// retrieve our custom resource informer which was generated from
 	// the code generator and pass it the custom resource client, specifying
-	// we should be looking through all namespaces for listing and watching
+	// the namespace to look through for listing and watching, all of them
+	// unless the configuration limits it
 	informer := myresourceinformer_v1.NewMyResourceInformer(
 		myResourceClient,
-		meta_v1.NamespaceAll,
-		0,
+		cfg.Namespace,
+		cfg.ResyncPeriod.Duration,
 		cache.Indexers{},
 	)
 
+	// the Deployments, Services and Ingresses owned by MyResources are watched
+	// by more informers, the service reads them from their caches instead of
+	// the API server
+	deploymentInformer := worker.NewDeploymentInformer(client, cfg.Namespace, cfg.ResyncPeriod.Duration)
+	serviceInformer := worker.NewServiceInformer(client, cfg.Namespace, cfg.ResyncPeriod.Duration)
+	ingressInformer := worker.NewIngressInformer(client, cfg.Namespace, cfg.ResyncPeriod.Duration)
+
 	// create a new queue so that when the informer gets a resource that is either
 	// a result of listing or watching, we can add an idenfitying key to the queue
-	// so that it can be handled in the handler
-	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	// so that it can be handled by the reconciler. The queue only holds keys, so
+	// several events for the same resource coalesce into a single reconcile
+	queue := workqueue.NewNamedRateLimitingQueue(newRateLimiter(cfg.RateLimiter), "myresource")
 
-	// add event handlers to handle the three types of events for resources:
+	// the managed MyResources are counted from the informer cache
+	metrics.SetManagedResourcesFunc(func() int {
+		return len(informer.GetStore().ListKeys())
+	})
+
+	// add event handlers for the three types of events for resources, they all
+	// just enqueue the key since the reconciler works from the current state:
 	//  - adding new resources
 	//  - updating existing resources
 	//  - deleting resources
-	var newEvent worker.Event
-	var err error
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			// convert the resource object into a key (in this case
 			// we are just doing it in the format of 'namespace/name')
-			newEvent.Key, err = cache.MetaNamespaceKeyFunc(obj)
-			newEvent.EventType = "create"
-			log.Infof("Add myresource: %s", newEvent.Key)
+			key, err := cache.MetaNamespaceKeyFunc(obj)
+			log.Infof("Add myresource: %s", key)
 			if err == nil {
-				// add the key to the queue for the handler to get
-				queue.Add(newEvent)
+				// add the key to the queue for the reconciler to get
+				queue.Add(key)
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
-			newEvent.Key, err = cache.MetaNamespaceKeyFunc(oldObj)
-			newEvent.EventType = "update"
-			newEvent.OldObj = oldObj
-			log.Infof("Update myresource: %s", newEvent.Key)
+			key, err := cache.MetaNamespaceKeyFunc(newObj)
+			log.Infof("Update myresource: %s", key)
 			if err == nil {
-				queue.Add(newEvent)
+				queue.Add(key)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
@@ -68,34 +247,112 @@ func main() {
 			// a resource was deleted but it is still contained in the index
 			//
 			// this then in turn calls MetaNamespaceKeyFunc
-			newEvent.Key, err = cache.MetaNamespaceKeyFunc(obj)
-			newEvent.EventType = "delete"
-			newEvent.OldObj = obj
-			log.Infof("Delete myresource: %s", newEvent.Key)
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			log.Infof("Delete myresource: %s", key)
 			if err == nil {
-				queue.Add(newEvent)
+				queue.Add(key)
 			}
 		},
 	})
 
+	// record the events of the MyResources in the API server, so that they
+	// show in kubectl describe, and in the log
+	myresourcescheme.AddToScheme(scheme.Scheme)
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(log.Infof)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, apiv1.EventSource{Component: "myresource-controller"})
+
 	// construct the Controller object which has all of the necessary components to
 	// handle logging, connections, informing (listing and watching), the queue,
-	// and the handler
-
-	controller := worker.Controller {
-		Logger:    log.NewEntry(log.New()),
-		Clientset: client,
-		Informer:  informer,
-		Queue:     queue,
-		Handler:   &worker.MyResourceHandler{},
+	// and the reconciler
+
+	controller := worker.Controller{
+		Logger:             log.NewEntry(log.New()),
+		Clientset:          client,
+		Informer:           informer,
+		DeploymentInformer: deploymentInformer,
+		ServiceInformer:    serviceInformer,
+		IngressInformer:    ingressInformer,
+		Queue:              queue,
+		Reconciler: &worker.MyResourceReconciler{
+			Lister: myresourcelister_v1.NewMyResourceLister(informer.GetIndexer()),
+			Service: service.NewHttpService(client, myResourceClient,
+				appslister_v1.NewDeploymentLister(deploymentInformer.GetIndexer()),
+				corelister_v1.NewServiceLister(serviceInformer.GetIndexer()),
+				extensionslister_v1beta1.NewIngressLister(ingressInformer.GetIndexer()), recorder),
+		},
+		Recorder:         recorder,
+		Workers:          cfg.Workers,
+		MaxRetries:       cfg.MaxRetries,
+		CacheSyncTimeout: cfg.CacheSyncTimeout.Duration,
+		StallTimeout:     cfg.WorkerStallTimeout.Duration,
 	}
 
-	// use a channel to synchronize the finalization for a graceful shutdown
-	stopCh := make(chan struct{})
-	defer close(stopCh)
+	// the replica is ready once its informers have synced, which only
+	// starts after it became the leader when leader election is enabled,
+	// and it is alive as long as no worker is stuck on a reconcile. The
+	// webhooks don't depend on readiness, their Service publishes the
+	// replicas which aren't ready
+	var leading int32
+	readyChecks := []health.NamedCheck{{Name: "informers", Check: controller.Ready}}
+	if cfg.LeaderElection.Enabled {
+		readyChecks = append(readyChecks, health.NamedCheck{Name: "leader", Check: func() error {
+			if atomic.LoadInt32(&leading) == 0 {
+				return fmt.Errorf("not the leader")
+			}
+			return nil
+		}})
+	}
+	if cfg.HealthProbeBindAddress != "" {
+		server := serve(cfg.HealthProbeBindAddress, map[string]http.Handler{
+			"/healthz": health.Handler(health.NamedCheck{Name: "workers", Check: controller.Healthy}),
+			"/readyz":  health.Handler(readyChecks...),
+		})
+		defer server.Close()
+	}
+
+	// exit non-zero when the informers can't sync, a restart may help
+	// and the process must not look alive while it does nothing
+	runController := func(stopCh <-chan struct{}) {
+		atomic.StoreInt32(&leading, 1)
+		metrics.SetLeader(true)
+		defer metrics.SetLeader(false)
+		defer atomic.StoreInt32(&leading, 0)
+		if err := controller.Run(stopCh); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	// run the controller loop to process items
-	go controller.Run(stopCh)
+	// use a context to synchronize the finalization for a graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	doneCh := make(chan struct{})
+
+	// run the controller loop to process items, when leader election is
+	// enabled only while this replica holds the leadership
+	go func() {
+		defer close(doneCh)
+		if !cfg.LeaderElection.Enabled {
+			runController(ctx.Done())
+			return
+		}
+		err := election.Run(ctx, client, election.Config{
+			LockType:      cfg.LeaderElection.ResourceLock,
+			LockNamespace: cfg.LeaderElection.Namespace,
+			LockName:      cfg.LeaderElection.Name,
+			LeaseDuration: cfg.LeaderElection.LeaseDuration.Duration,
+			RenewDeadline: cfg.LeaderElection.RenewDeadline.Duration,
+			RetryPeriod:   cfg.LeaderElection.RetryPeriod.Duration,
+			// exit so that the replica restarts as a candidate
+			// with a fresh informer cache and queue
+			OnLostLeadership: func() {
+				log.Fatal("Leader election lost")
+			},
+		}, runController)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
 
 	// use a channel to handle OS signals to terminate and gracefully shut
 	// down processing
@@ -103,4 +360,9 @@ func main() {
 	signal.Notify(sigTerm, syscall.SIGTERM)
 	signal.Notify(sigTerm, syscall.SIGINT)
 	<-sigTerm
+
+	// stop the controller, wait for the items in flight to finish
+	// and release the leadership
+	cancel()
+	<-doneCh
 }
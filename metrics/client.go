@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+)
+
+// the metrics of the requests of the client-go REST clients, the URL is only
+// kept by host since the paths contain the object names
+var (
+	requestLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rest_client_request_latency_seconds",
+		Help:    "Request latency in seconds, broken down by verb and host.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 10),
+	}, []string{"verb", "host"})
+
+	requestResult = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rest_client_requests_total",
+		Help: "Number of HTTP requests, partitioned by status code, method, and host.",
+	}, []string{"code", "method", "host"})
+)
+
+func registerClientMetrics() {
+	Registry.MustRegister(requestLatency, requestResult)
+	clientmetrics.Register(latencyAdapter{}, resultAdapter{})
+}
+
+type latencyAdapter struct{}
+
+func (latencyAdapter) Observe(verb string, u url.URL, latency time.Duration) {
+	requestLatency.WithLabelValues(verb, u.Host).Observe(latency.Seconds())
+}
+
+type resultAdapter struct{}
+
+func (resultAdapter) Increment(code, method, host string) {
+	requestResult.WithLabelValues(code, method, host).Inc()
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// the outcomes of a reconcile, the label of myresource_reconcile_total
+const (
+	// OutcomeSuccess is a reconcile without error
+	OutcomeSuccess = "success"
+	// OutcomeRequeue is a reconcile waiting for the cluster, it is retried
+	// later without counting as a failure
+	OutcomeRequeue = "requeue"
+	// OutcomeError is a failed reconcile which is retried with rate limiting
+	OutcomeError = "error"
+	// OutcomeDropped is a failed reconcile of a key out of retries
+	OutcomeDropped = "dropped"
+)
+
+// Registry holds every metric of the controller, it is served by Handler
+var Registry = prometheus.NewRegistry()
+
+var (
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "myresource_reconcile_total",
+		Help: "Number of MyResource reconciles by outcome.",
+	}, []string{"outcome"})
+
+	reconcileDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "myresource_reconcile_duration_seconds",
+		Help:    "Duration of MyResource reconciles in seconds.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 15),
+	})
+
+	managedResources = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "myresource_managed_resources",
+		Help: "Number of MyResources in the cache of the controller.",
+	}, func() float64 {
+		managedResourcesLock.Lock()
+		defer managedResourcesLock.Unlock()
+		if managedResourcesCount == nil {
+			return 0
+		}
+		return float64(managedResourcesCount())
+	})
+	managedResourcesLock  sync.Mutex
+	managedResourcesCount func() int
+
+	leader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "myresource_leader",
+		Help: "Whether this replica runs the controller loop, 1 for the leader and 0 for a standby.",
+	})
+
+	registerOnce sync.Once
+)
+
+// Register adds the metrics of the controller, the work queues and the
+// clients to the Registry. Only the first call has an effect and it must
+// happen before the queues are created
+func Register() {
+	registerOnce.Do(func() {
+		Registry.MustRegister(
+			prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+			prometheus.NewGoCollector(),
+			reconcileTotal,
+			reconcileDuration,
+			managedResources,
+			leader,
+		)
+		registerWorkqueueMetrics()
+		registerClientMetrics()
+	})
+}
+
+// RecordReconcile counts a finished reconcile and its duration
+func RecordReconcile(outcome string, duration time.Duration) {
+	reconcileTotal.WithLabelValues(outcome).Inc()
+	reconcileDuration.Observe(duration.Seconds())
+}
+
+// SetManagedResourcesFunc sets the function counting the managed MyResources,
+// it is called on every scrape
+func SetManagedResourcesFunc(count func() int) {
+	managedResourcesLock.Lock()
+	defer managedResourcesLock.Unlock()
+	managedResourcesCount = count
+}
+
+// SetLeader records whether this replica runs the controller loop
+func SetLeader(leading bool) {
+	if leading {
+		leader.Set(1)
+	} else {
+		leader.Set(0)
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
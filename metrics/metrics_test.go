@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// scrape returns the metrics served by Handler on a local listener
+func scrape(t *testing.T) string {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestHandlerServesControllerMetrics(t *testing.T) {
+	Register()
+	// registering again is a no-op instead of a duplicate registration panic
+	Register()
+
+	// the counters are global, only look at what this test adds
+	successes := testutil.ToFloat64(reconcileTotal.WithLabelValues(OutcomeSuccess))
+	RecordReconcile(OutcomeSuccess, 10*time.Millisecond)
+	RecordReconcile(OutcomeSuccess, 20*time.Millisecond)
+	RecordReconcile(OutcomeDropped, time.Millisecond)
+	assert.Equal(t, successes+2, testutil.ToFloat64(reconcileTotal.WithLabelValues(OutcomeSuccess)))
+	SetManagedResourcesFunc(func() int { return 3 })
+	defer SetManagedResourcesFunc(nil)
+	SetLeader(true)
+	defer SetLeader(false)
+
+	body := scrape(t)
+	assert.Contains(t, body, `myresource_reconcile_total{outcome="dropped"}`)
+	assert.Contains(t, body, "myresource_reconcile_duration_seconds_count")
+	assert.Contains(t, body, "myresource_managed_resources 3")
+	assert.Contains(t, body, "myresource_leader 1")
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestHandlerServesWorkqueueMetrics(t *testing.T) {
+	Register()
+
+	// a new name for every run since the metrics are global
+	name := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	// the rate limited item must not become ready before the scrape
+	limiter := workqueue.NewItemExponentialFailureRateLimiter(time.Hour, time.Hour)
+	queue := workqueue.NewNamedRateLimitingQueue(limiter, name)
+	defer queue.ShutDown()
+	queue.Add("default/a")
+	queue.Add("default/b")
+	item, _ := queue.Get()
+	queue.AddRateLimited("default/c")
+	queue.Done(item)
+
+	body := scrape(t)
+	assert.Contains(t, body, `workqueue_adds_total{name="`+name+`"} 2`)
+	assert.Contains(t, body, `workqueue_depth{name="`+name+`"} 1`)
+	assert.Contains(t, body, `workqueue_retries_total{name="`+name+`"} 1`)
+	assert.Contains(t, body, `workqueue_queue_duration_seconds_count{name="`+name+`"} 1`)
+	assert.Contains(t, body, `workqueue_work_duration_seconds_count{name="`+name+`"} 1`)
+}
+
+func TestHandlerServesClientMetrics(t *testing.T) {
+	Register()
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`,
+			http.StatusNotFound)
+	}))
+	defer apiServer.Close()
+	client := kubernetes.NewForConfigOrDie(&restclient.Config{Host: apiServer.URL})
+	_, err := client.CoreV1().Pods("default").Get("missing", metav1.GetOptions{})
+	assert.NotNil(t, err)
+
+	host := strings.TrimPrefix(apiServer.URL, "http://")
+	body := scrape(t)
+	assert.Contains(t, body, `rest_client_requests_total{code="404",host="`+host+`",method="GET"} 1`)
+	assert.Contains(t, body, `rest_client_request_latency_seconds_count{host="`+host+`",verb="GET"} 1`)
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// the work queue metrics, labeled with the name of the queue
+var (
+	workqueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "workqueue_depth",
+		Help: "Current depth of the work queue.",
+	}, []string{"name"})
+
+	workqueueAdds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "workqueue_adds_total",
+		Help: "Number of adds handled by the work queue.",
+	}, []string{"name"})
+
+	workqueueLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "workqueue_queue_duration_seconds",
+		Help:    "How long in seconds an item stays in the work queue before being requested.",
+		Buckets: prometheus.ExponentialBuckets(10e-9, 10, 10),
+	}, []string{"name"})
+
+	workqueueWorkDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "workqueue_work_duration_seconds",
+		Help:    "How long in seconds processing an item from the work queue takes.",
+		Buckets: prometheus.ExponentialBuckets(10e-9, 10, 10),
+	}, []string{"name"})
+
+	workqueueUnfinishedWork = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "workqueue_unfinished_work_seconds",
+		Help: "How many seconds of work has been done that is in progress and hasn't been observed by work_duration.",
+	}, []string{"name"})
+
+	workqueueLongestRunningProcessor = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "workqueue_longest_running_processor_seconds",
+		Help: "How many seconds the longest running processor of the work queue has been running.",
+	}, []string{"name"})
+
+	workqueueRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "workqueue_retries_total",
+		Help: "Number of retries handled by the work queue.",
+	}, []string{"name"})
+)
+
+func registerWorkqueueMetrics() {
+	Registry.MustRegister(
+		workqueueDepth,
+		workqueueAdds,
+		workqueueLatency,
+		workqueueWorkDuration,
+		workqueueUnfinishedWork,
+		workqueueLongestRunningProcessor,
+		workqueueRetries,
+	)
+	workqueue.SetProvider(workqueueMetricsProvider{})
+}
+
+// workqueueMetricsProvider creates the metrics of named work queues, the
+// queues report durations in microseconds which are exported in seconds
+type workqueueMetricsProvider struct{}
+
+func (workqueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return workqueueDepth.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return workqueueAdds.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLatencyMetric(name string) workqueue.SummaryMetric {
+	return microseconds{workqueueLatency.WithLabelValues(name)}
+}
+
+func (workqueueMetricsProvider) NewWorkDurationMetric(name string) workqueue.SummaryMetric {
+	return microseconds{workqueueWorkDuration.WithLabelValues(name)}
+}
+
+func (workqueueMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueUnfinishedWork.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLongestRunningProcessorMicrosecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return microsecondsGauge{workqueueLongestRunningProcessor.WithLabelValues(name)}
+}
+
+func (workqueueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return workqueueRetries.WithLabelValues(name)
+}
+
+type microseconds struct {
+	observer prometheus.Observer
+}
+
+func (m microseconds) Observe(value float64) {
+	m.observer.Observe(value / 1e6)
+}
+
+type microsecondsGauge struct {
+	gauge prometheus.Gauge
+}
+
+func (m microsecondsGauge) Set(value float64) {
+	m.gauge.Set(value / 1e6)
+}
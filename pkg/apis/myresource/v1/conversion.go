@@ -0,0 +1,209 @@
+package v1
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	v2 "k8s-controller-custom-resource/pkg/apis/myresource/v2"
+)
+
+// DeprecatedFieldsAnnotation keeps the fields of v1 which v2 dropped, so
+// that a MyResource read as v2 converts back to the same v1 object
+const DeprecatedFieldsAnnotation = "trstringer.com/v1-deprecated-fields"
+
+// deprecatedFields is the value of the DeprecatedFieldsAnnotation. The
+// flags record that a v2 field was only filled from a deprecated field
+type deprecatedFields struct {
+	Message           string `json:"message,omitempty"`
+	SomeValue         *int32 `json:"someValue,omitempty"`
+	ImageFromMessage  bool   `json:"imageFromMessage,omitempty"`
+	HTTPFromSomeValue bool   `json:"httpFromSomeValue,omitempty"`
+}
+
+func addConversionFuncs(scheme *runtime.Scheme) error {
+	err := scheme.AddConversionFunc((*MyResource)(nil), (*v2.MyResource)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1_MyResource_To_v2_MyResource(a.(*MyResource), b.(*v2.MyResource), scope)
+	})
+	if err != nil {
+		return err
+	}
+	return scheme.AddConversionFunc((*v2.MyResource)(nil), (*MyResource)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v2_MyResource_To_v1_MyResource(a.(*v2.MyResource), b.(*MyResource), scope)
+	})
+}
+
+// Convert_v1_MyResource_To_v2_MyResource converts a MyResource to v2, the
+// deprecated Message and SomeValue end up in the container image and the
+// HTTP methods, and in the DeprecatedFieldsAnnotation to convert back
+func Convert_v1_MyResource_To_v2_MyResource(in *MyResource, out *v2.MyResource, s conversion.Scope) error {
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+
+	spec := in.Spec.DeepCopy()
+	deprecated := deprecatedFields{Message: spec.Message, SomeValue: spec.SomeValue}
+	if spec.Image == "" && spec.Message != "" {
+		spec.Image = spec.Message
+		deprecated.ImageFromMessage = true
+	}
+	if spec.HTTP == nil && spec.SomeValue != nil {
+		spec.HTTP = HTTPSpecFromSomeValue(*spec.SomeValue)
+		deprecated.HTTPFromSomeValue = true
+	}
+	if deprecated.Message != "" || deprecated.SomeValue != nil {
+		data, err := json.Marshal(deprecated)
+		if err != nil {
+			return err
+		}
+		if out.Annotations == nil {
+			out.Annotations = map[string]string{}
+		}
+		out.Annotations[DeprecatedFieldsAnnotation] = string(data)
+	}
+
+	out.Spec = v2.MyResourceSpec{
+		Replicas: spec.Replicas,
+		Container: v2.ContainerSpec{
+			Image:           spec.Image,
+			ImagePullPolicy: spec.ImagePullPolicy,
+			Port:            spec.ContainerPort,
+			Resources:       spec.Resources,
+			Env:             spec.Env,
+			EnvFrom:         spec.EnvFrom,
+		},
+		DeletionPolicy: v2.DeletionPolicy(spec.DeletionPolicy),
+	}
+	if spec.HTTP != nil {
+		out.Spec.HTTP = &v2.HTTPSpec{}
+		if spec.HTTP.Methods != nil {
+			out.Spec.HTTP.Methods = make([]v2.HTTPMethod, len(spec.HTTP.Methods))
+			for i, method := range spec.HTTP.Methods {
+				out.Spec.HTTP.Methods[i] = v2.HTTPMethod(method)
+			}
+		}
+	}
+	if spec.Service != nil {
+		service := v2.ServiceSpec(*spec.Service)
+		out.Spec.Service = &service
+	}
+	if spec.Ingress != nil {
+		ingress := v2.IngressSpec(*spec.Ingress)
+		out.Spec.Ingress = &ingress
+	}
+
+	status := in.Status.DeepCopy()
+	out.Status = v2.MyResourceStatus{
+		ObservedGeneration: status.ObservedGeneration,
+		AvailableReplicas:  status.AvailableReplicas,
+		ServiceDNSName:     status.ServiceDNSName,
+		URL:                status.URL,
+		LastError:          status.LastError,
+	}
+	if status.Conditions != nil {
+		out.Status.Conditions = make([]v2.MyResourceCondition, len(status.Conditions))
+		for i, condition := range status.Conditions {
+			out.Status.Conditions[i] = v2.MyResourceCondition{
+				Type:               v2.MyResourceConditionType(condition.Type),
+				Status:             condition.Status,
+				LastTransitionTime: condition.LastTransitionTime,
+				Reason:             condition.Reason,
+				Message:            condition.Message,
+			}
+		}
+	}
+	return nil
+}
+
+// Convert_v2_MyResource_To_v1_MyResource converts a MyResource to v1, the
+// deprecated fields are restored from the DeprecatedFieldsAnnotation
+func Convert_v2_MyResource_To_v1_MyResource(in *v2.MyResource, out *MyResource, s conversion.Scope) error {
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+
+	spec := in.Spec.DeepCopy()
+	out.Spec = MyResourceSpec{
+		Image:           spec.Container.Image,
+		ImagePullPolicy: spec.Container.ImagePullPolicy,
+		Replicas:        spec.Replicas,
+		ContainerPort:   spec.Container.Port,
+		Resources:       spec.Container.Resources,
+		Env:             spec.Container.Env,
+		EnvFrom:         spec.Container.EnvFrom,
+		DeletionPolicy:  DeletionPolicy(spec.DeletionPolicy),
+	}
+	if spec.HTTP != nil {
+		out.Spec.HTTP = &HTTPSpec{}
+		if spec.HTTP.Methods != nil {
+			out.Spec.HTTP.Methods = make([]HTTPMethod, len(spec.HTTP.Methods))
+			for i, method := range spec.HTTP.Methods {
+				out.Spec.HTTP.Methods[i] = HTTPMethod(method)
+			}
+		}
+	}
+	if spec.Service != nil {
+		service := ServiceSpec(*spec.Service)
+		out.Spec.Service = &service
+	}
+	if spec.Ingress != nil {
+		ingress := IngressSpec(*spec.Ingress)
+		out.Spec.Ingress = &ingress
+	}
+
+	// an annotation which doesn't parse is dropped, the v2 fields are
+	// complete without it
+	if data, ok := out.Annotations[DeprecatedFieldsAnnotation]; ok {
+		var deprecated deprecatedFields
+		if err := json.Unmarshal([]byte(data), &deprecated); err == nil {
+			out.Spec.Message = deprecated.Message
+			out.Spec.SomeValue = deprecated.SomeValue
+			// the v2 fields changed since they were filled from the
+			// deprecated ones when they differ now
+			if deprecated.ImageFromMessage && out.Spec.Image == deprecated.Message {
+				out.Spec.Image = ""
+			}
+			if deprecated.HTTPFromSomeValue && deprecated.SomeValue != nil && out.Spec.HTTP != nil &&
+				sameMethods(out.Spec.HTTP.Methods, HTTPSpecFromSomeValue(*deprecated.SomeValue).Methods) {
+				out.Spec.HTTP = nil
+			}
+		}
+		delete(out.Annotations, DeprecatedFieldsAnnotation)
+		if len(out.Annotations) == 0 {
+			out.Annotations = nil
+		}
+	}
+
+	status := in.Status.DeepCopy()
+	out.Status = MyResourceStatus{
+		ObservedGeneration: status.ObservedGeneration,
+		AvailableReplicas:  status.AvailableReplicas,
+		ServiceDNSName:     status.ServiceDNSName,
+		URL:                status.URL,
+		LastError:          status.LastError,
+	}
+	if status.Conditions != nil {
+		out.Status.Conditions = make([]MyResourceCondition, len(status.Conditions))
+		for i, condition := range status.Conditions {
+			out.Status.Conditions[i] = MyResourceCondition{
+				Type:               MyResourceConditionType(condition.Type),
+				Status:             condition.Status,
+				LastTransitionTime: condition.LastTransitionTime,
+				Reason:             condition.Reason,
+				Message:            condition.Message,
+			}
+		}
+	}
+	return nil
+}
+
+// sameMethods returns whether both lists have the same methods in the
+// same order
+func sameMethods(a, b []HTTPMethod) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package v1
+
+import (
+	"math/rand"
+	"testing"
+
+	fuzz "github.com/google/gofuzz"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/apitesting/fuzzer"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metafuzzer "k8s.io/apimachinery/pkg/apis/meta/fuzzer"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/diff"
+
+	v2 "k8s-controller-custom-resource/pkg/apis/myresource/v2"
+)
+
+// fuzzRounds is the number of random objects converted in each direction
+const fuzzRounds = 1000
+
+func newFuzzer(t *testing.T) (*fuzz.Fuzzer, *runtime.Scheme) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	funcs := fuzzer.MergeFuzzerFuncs(metafuzzer.Funcs, func(serializer.CodecFactory) []interface{} {
+		return []interface{}{
+			func(q *resource.Quantity, c fuzz.Continue) {
+				*q = *resource.NewQuantity(c.Int63n(1000), resource.DecimalSI)
+			},
+		}
+	})
+	return fuzzer.FuzzerFor(funcs, rand.NewSource(rand.Int63()), serializer.NewCodecFactory(scheme)), scheme
+}
+
+func TestConversionRoundTripFromV1(t *testing.T) {
+	f, scheme := newFuzzer(t)
+	for i := 0; i < fuzzRounds; i++ {
+		in := &MyResource{}
+		f.Fuzz(in)
+		in.TypeMeta = meta_v1.TypeMeta{}
+
+		hub := &v2.MyResource{}
+		if err := scheme.Convert(in.DeepCopy(), hub, nil); err != nil {
+			t.Fatal(err)
+		}
+		out := &MyResource{}
+		if err := scheme.Convert(hub, out, nil); err != nil {
+			t.Fatal(err)
+		}
+		if !equality.Semantic.DeepEqual(in, out) {
+			t.Fatalf("v1 changed by the round trip through v2:\n%s", diff.ObjectReflectDiff(in, out))
+		}
+	}
+}
+
+func TestConversionRoundTripFromV2(t *testing.T) {
+	f, scheme := newFuzzer(t)
+	for i := 0; i < fuzzRounds; i++ {
+		in := &v2.MyResource{}
+		f.Fuzz(in)
+		in.TypeMeta = meta_v1.TypeMeta{}
+
+		spoke := &MyResource{}
+		if err := scheme.Convert(in.DeepCopy(), spoke, nil); err != nil {
+			t.Fatal(err)
+		}
+		out := &v2.MyResource{}
+		if err := scheme.Convert(spoke, out, nil); err != nil {
+			t.Fatal(err)
+		}
+		if !equality.Semantic.DeepEqual(in, out) {
+			t.Fatalf("v2 changed by the round trip through v1:\n%s", diff.ObjectReflectDiff(in, out))
+		}
+	}
+}
+
+func TestConvertDeprecatedFields(t *testing.T) {
+	someValue := int32(3)
+	in := &MyResource{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "demo", Namespace: "default"},
+		Spec:       MyResourceSpec{Message: "nginx:1.15", SomeValue: &someValue},
+	}
+	out := &v2.MyResource{}
+	assert.Nil(t, Convert_v1_MyResource_To_v2_MyResource(in, out, nil))
+	assert.Equal(t, "nginx:1.15", out.Spec.Container.Image)
+	assert.Equal(t, &v2.HTTPSpec{Methods: []v2.HTTPMethod{"GET", "PUT"}}, out.Spec.HTTP)
+	assert.JSONEq(t, `{"message":"nginx:1.15","someValue":3,"imageFromMessage":true,"httpFromSomeValue":true}`,
+		out.Annotations[DeprecatedFieldsAnnotation])
+	assert.Nil(t, in.Annotations)
+
+	// a v2 client replaced the image, v1 sees the new one with the old message
+	out.Spec.Container.Image = "nginx:1.16"
+	back := &MyResource{}
+	assert.Nil(t, Convert_v2_MyResource_To_v1_MyResource(out, back, nil))
+	assert.Equal(t, "nginx:1.16", back.Spec.Image)
+	assert.Equal(t, "nginx:1.15", back.Spec.Message)
+	assert.Equal(t, &someValue, back.Spec.SomeValue)
+	assert.Nil(t, back.Spec.HTTP)
+	assert.Nil(t, back.Annotations)
+
+	// an invalid annotation is dropped
+	out.Annotations[DeprecatedFieldsAnnotation] = "{"
+	back = &MyResource{}
+	assert.Nil(t, Convert_v2_MyResource_To_v1_MyResource(out, back, nil))
+	assert.Equal(t, "", back.Spec.Message)
+	assert.Nil(t, back.Spec.SomeValue)
+	assert.Equal(t, &HTTPSpec{Methods: []HTTPMethod{"GET", "PUT"}}, back.Spec.HTTP)
+	assert.Nil(t, back.Annotations)
+}
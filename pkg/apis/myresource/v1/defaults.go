@@ -0,0 +1,98 @@
+package v1
+
+import (
+	"strings"
+
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// the ports used when the spec omits them
+const (
+	DefaultContainerPort = 8888
+	DefaultServicePort   = 80
+)
+
+// the standard labels of a MyResource and its child resources, see
+// https://kubernetes.io/docs/concepts/overview/working-with-objects/common-labels/
+const (
+	NameLabel     = "app.kubernetes.io/name"
+	InstanceLabel = "app.kubernetes.io/instance"
+
+	// AppName is the value of the NameLabel
+	AppName = "myresource"
+)
+
+func addDefaultingFuncs(scheme *runtime.Scheme) error {
+	return RegisterDefaults(scheme)
+}
+
+// SetDefaults_MyResource adds the standard labels which are missing
+func SetDefaults_MyResource(obj *MyResource) {
+	if obj.Labels == nil {
+		obj.Labels = map[string]string{}
+	}
+	if _, ok := obj.Labels[NameLabel]; !ok {
+		obj.Labels[NameLabel] = AppName
+	}
+	if _, ok := obj.Labels[InstanceLabel]; !ok && obj.Name != "" {
+		obj.Labels[InstanceLabel] = obj.Name
+	}
+}
+
+// SetDefaults_MyResourceSpec fills the omitted fields of the spec. The
+// deprecated Message and SomeValue are moved to the Image and the HTTP
+// methods of older resources, SomeValue is ignored when HTTP is set
+func SetDefaults_MyResourceSpec(obj *MyResourceSpec) {
+	if obj.Image == "" {
+		obj.Image = obj.Message
+	}
+	obj.Message = ""
+	if obj.HTTP == nil {
+		obj.HTTP = obj.EffectiveHTTP()
+	}
+	obj.SomeValue = nil
+	if obj.ImagePullPolicy == "" {
+		// the same rule as the API server applies to containers
+		if imageTag(obj.Image) == "latest" {
+			obj.ImagePullPolicy = core_v1.PullAlways
+		} else {
+			obj.ImagePullPolicy = core_v1.PullIfNotPresent
+		}
+	}
+	if obj.Replicas == nil {
+		replicas := int32(1)
+		obj.Replicas = &replicas
+	}
+	if obj.ContainerPort == 0 {
+		obj.ContainerPort = DefaultContainerPort
+	}
+	if obj.Service == nil {
+		obj.Service = &ServiceSpec{}
+	}
+	if obj.Service.Type == "" {
+		obj.Service.Type = core_v1.ServiceTypeClusterIP
+	}
+	if obj.Service.Port == 0 {
+		obj.Service.Port = DefaultServicePort
+	}
+	if obj.Ingress != nil && obj.Ingress.Path == "" {
+		obj.Ingress.Path = "/"
+	}
+	if obj.DeletionPolicy == "" {
+		obj.DeletionPolicy = DeletionPolicyDelete
+	}
+}
+
+// imageTag returns the tag of an image reference, latest when it has none
+// and nothing when the image is pinned by a digest
+func imageTag(image string) string {
+	if strings.Contains(image, "@") {
+		return ""
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return "latest"
+}
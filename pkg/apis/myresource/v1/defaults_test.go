@@ -0,0 +1,75 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestSetDefaultsMyResourceSpec(t *testing.T) {
+	for image, policy := range map[string]core_v1.PullPolicy{
+		"nginx":                            core_v1.PullAlways,
+		"nginx:latest":                     core_v1.PullAlways,
+		"nginx:1.15":                       core_v1.PullIfNotPresent,
+		"localhost:5000/nginx":             core_v1.PullAlways,
+		"localhost:5000/nginx:1.15":        core_v1.PullIfNotPresent,
+		"nginx@sha256:0123456789abcdef":    core_v1.PullIfNotPresent,
+		"registry.example.com/team/web:v2": core_v1.PullIfNotPresent,
+	} {
+		spec := MyResourceSpec{Image: image}
+		SetDefaults_MyResourceSpec(&spec)
+		assert.Equal(t, policy, spec.ImagePullPolicy, image)
+	}
+
+	someValue := int32(3)
+	spec := MyResourceSpec{Message: "nginx:1.15", SomeValue: &someValue}
+	SetDefaults_MyResourceSpec(&spec)
+	assert.Equal(t, "nginx:1.15", spec.Image)
+	// the deprecated fields are moved to the new ones
+	assert.Equal(t, "", spec.Message)
+	assert.Nil(t, spec.SomeValue)
+	assert.Equal(t, &HTTPSpec{Methods: []HTTPMethod{HTTPMethodGet, HTTPMethodPut}}, spec.HTTP)
+	assert.Equal(t, DeletionPolicyDelete, spec.DeletionPolicy)
+	assert.Equal(t, int32(1), *spec.Replicas)
+	assert.Equal(t, int32(DefaultContainerPort), spec.ContainerPort)
+	assert.Equal(t, &ServiceSpec{Type: core_v1.ServiceTypeClusterIP, Port: DefaultServicePort}, spec.Service)
+	assert.Nil(t, spec.Ingress)
+
+	spec = MyResourceSpec{Image: "nginx", Ingress: &IngressSpec{Host: "demo.example.com"}}
+	SetDefaults_MyResourceSpec(&spec)
+	assert.Equal(t, "/", spec.Ingress.Path)
+
+	// set fields are kept
+	replicas := int32(0)
+	spec = MyResourceSpec{Image: "web", Message: "nginx", Replicas: &replicas, ContainerPort: 80,
+		ImagePullPolicy: core_v1.PullNever, Service: &ServiceSpec{Type: core_v1.ServiceTypeNodePort, Port: 8080}}
+	SetDefaults_MyResourceSpec(&spec)
+	assert.Equal(t, "web", spec.Image)
+	assert.Equal(t, int32(0), *spec.Replicas)
+	assert.Equal(t, int32(80), spec.ContainerPort)
+	assert.Equal(t, core_v1.PullNever, spec.ImagePullPolicy)
+	assert.Equal(t, &ServiceSpec{Type: core_v1.ServiceTypeNodePort, Port: 8080}, spec.Service)
+}
+
+func TestSetDefaultsMyResource(t *testing.T) {
+	resource := MyResource{ObjectMeta: meta_v1.ObjectMeta{Name: "demo"}}
+	SetDefaults_MyResource(&resource)
+	assert.Equal(t, map[string]string{NameLabel: AppName, InstanceLabel: "demo"}, resource.Labels)
+
+	// set labels are kept
+	resource.Labels = map[string]string{InstanceLabel: "blue", "team": "a"}
+	SetDefaults_MyResource(&resource)
+	assert.Equal(t, map[string]string{NameLabel: AppName, InstanceLabel: "blue", "team": "a"}, resource.Labels)
+}
+
+func TestSchemeAppliesDefaults(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, AddToScheme(scheme))
+
+	list := &MyResourceList{Items: []MyResource{{Spec: MyResourceSpec{Image: "nginx"}}}}
+	scheme.Default(list)
+	assert.Equal(t, int32(DefaultContainerPort), list.Items[0].Spec.ContainerPort)
+}
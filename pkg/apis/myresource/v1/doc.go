@@ -1,4 +1,5 @@
 // +k8s:deepcopy-gen=package
+// +k8s:defaulter-gen=TypeMeta
 // +groupName=trstringer.com
 
 package v1
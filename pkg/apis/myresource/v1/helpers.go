@@ -0,0 +1,42 @@
+package v1
+
+// HTTPMethods lists every supported HTTPMethod, in the order of the env
+// vars of the container
+var HTTPMethods = []HTTPMethod{HTTPMethodGet, HTTPMethodPut, HTTPMethodPost, HTTPMethodDelete, HTTPMethodPatch}
+
+// IsSupported returns whether the HTTP server knows the method
+func (m HTTPMethod) IsSupported() bool {
+	for _, method := range HTTPMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTPSpecFromSomeValue translates the deprecated SomeValue into the
+// HTTPSpec with the same methods
+func HTTPSpecFromSomeValue(someValue int32) *HTTPSpec {
+	switch someValue {
+	case 2:
+		return &HTTPSpec{Methods: []HTTPMethod{HTTPMethodPut}}
+	case 3:
+		return &HTTPSpec{Methods: []HTTPMethod{HTTPMethodGet, HTTPMethodPut}}
+	case 4:
+		return &HTTPSpec{Methods: []HTTPMethod{}}
+	default:
+		return &HTTPSpec{Methods: []HTTPMethod{HTTPMethodGet}}
+	}
+}
+
+// EffectiveHTTP returns the HTTP of the spec, translated from the deprecated
+// SomeValue when only that is set. The server accepts GET when neither is set
+func (spec *MyResourceSpec) EffectiveHTTP() *HTTPSpec {
+	if spec.HTTP != nil {
+		return spec.HTTP
+	}
+	if spec.SomeValue != nil {
+		return HTTPSpecFromSomeValue(*spec.SomeValue)
+	}
+	return &HTTPSpec{Methods: []HTTPMethod{HTTPMethodGet}}
+}
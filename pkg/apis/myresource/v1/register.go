@@ -19,7 +19,7 @@ var SchemeGroupVersion = schema.GroupVersion{
 // the scheme
 // more comments here
 var (
-	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes, addDefaultingFuncs, addConversionFuncs)
 	AddToScheme   = SchemeBuilder.AddToScheme
 )
 
@@ -0,0 +1,6 @@
+// +k8s:deepcopy-gen=package
+// +groupName=trstringer.com
+
+// Package v2 is the storage version of the MyResource API, it groups the
+// container fields of v1 and drops its deprecated fields
+package v2
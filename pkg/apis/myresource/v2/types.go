@@ -0,0 +1,175 @@
+package v2
+
+import (
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:path=myresources,scope=Namespaced
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name=Ready,type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name=Available,type=integer,JSONPath=.status.availableReplicas
+// +kubebuilder:printcolumn:name=Image,type=string,JSONPath=.spec.container.image,priority=1
+// +kubebuilder:printcolumn:name=Service,type=string,JSONPath=.status.serviceDNSName,priority=1
+// +kubebuilder:printcolumn:name=URL,type=string,JSONPath=.status.url
+// +kubebuilder:printcolumn:name=Age,type=date,JSONPath=.metadata.creationTimestamp
+
+// MyResource describes an HTTP server, with its Deployment, Service and
+// Ingress
+type MyResource struct {
+	meta_v1.TypeMeta   `json:",inline"`
+	meta_v1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the desired state of the HTTP server
+	Spec MyResourceSpec `json:"spec"`
+	// Status is the most recently observed state of the resource, written
+	// by the controller through the status subresource
+	Status MyResourceStatus `json:"status,omitempty"`
+}
+
+// MyResourceSpec is the spec for a MyResource resource
+type MyResourceSpec struct {
+	// Replicas is the number of pods, defaults to 1
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=1
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Container describes the container of the HTTP server
+	Container ContainerSpec `json:"container"`
+	// HTTP configures the HTTP server, it accepts GET when it is not set
+	HTTP *HTTPSpec `json:"http,omitempty"`
+	// Service configures the Service in front of the pods
+	Service *ServiceSpec `json:"service,omitempty"`
+	// Ingress exposes the Service outside of the cluster, no Ingress is
+	// created when it is not set
+	Ingress *IngressSpec `json:"ingress,omitempty"`
+	// DeletionPolicy decides what happens to the generated workload when
+	// the MyResource is deleted, defaults to Delete
+	// +kubebuilder:default=Delete
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// ContainerSpec describes the container of the HTTP server
+type ContainerSpec struct {
+	// Image is the container image of the HTTP server
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+	// ImagePullPolicy defaults to Always for images without a tag or
+	// with the latest tag and to IfNotPresent otherwise
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	ImagePullPolicy core_v1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// Port is the port the HTTP server listens on, defaults to 8888
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=8888
+	Port int32 `json:"port,omitempty"`
+	// Resources are the compute resource requests and limits of the container
+	Resources core_v1.ResourceRequirements `json:"resources,omitempty"`
+	// Env is added to the container after the ENABLE_<METHOD> env vars
+	// managed by the controller, so it can refer to them
+	Env []core_v1.EnvVar `json:"env,omitempty"`
+	// EnvFrom is added to the container like Env
+	EnvFrom []core_v1.EnvFromSource `json:"envFrom,omitempty"`
+}
+
+// HTTPSpec configures the HTTP server of the generated workload
+type HTTPSpec struct {
+	// Methods are the HTTP methods the server accepts, the others are
+	// rejected. An empty list disables all of them
+	Methods []HTTPMethod `json:"methods"`
+}
+
+// ServiceSpec configures the Service selecting the pods of a MyResource
+type ServiceSpec struct {
+	// Type is ClusterIP, NodePort or LoadBalancer, defaults to ClusterIP
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +kubebuilder:default=ClusterIP
+	Type core_v1.ServiceType `json:"type,omitempty"`
+	// Port is the port of the Service, defaults to 80
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=80
+	Port int32 `json:"port,omitempty"`
+	// NodePort is the port on every node for the NodePort and LoadBalancer
+	// types, the cluster allocates one when it is not set
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	NodePort int32 `json:"nodePort,omitempty"`
+}
+
+// IngressSpec configures the Ingress routing to the Service of a MyResource
+type IngressSpec struct {
+	// Host is the host name routed to the Service
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	Host string `json:"host"`
+	// Path is the path prefix routed to the Service, defaults to /
+	// +kubebuilder:validation:Pattern=^/
+	// +kubebuilder:default=/
+	Path string `json:"path,omitempty"`
+	// IngressClassName selects the ingress controller, the default one of
+	// the cluster serves the Ingress when it is not set
+	IngressClassName string `json:"ingressClassName,omitempty"`
+	// TLSSecretName is the Secret with the certificate of the host, the
+	// Ingress terminates TLS when it is set
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+}
+
+// HTTPMethod is a method the HTTP server can accept
+// +kubebuilder:validation:Enum=GET;PUT;POST;DELETE;PATCH
+type HTTPMethod string
+
+// DeletionPolicy is the policy applied to the child resources of a
+// MyResource when it is deleted
+// +kubebuilder:validation:Enum=Delete;Retain;Orphan
+type DeletionPolicy string
+
+// MyResourceStatus is the status for a MyResource resource
+type MyResourceStatus struct {
+	// ObservedGeneration is the metadata.generation of the MyResource
+	// that the controller last reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// AvailableReplicas is the number of available pods of the
+	// generated Deployment
+	AvailableReplicas int32 `json:"availableReplicas"`
+	// Conditions describe the current state of the generated workload
+	Conditions []MyResourceCondition `json:"conditions,omitempty"`
+	// ServiceDNSName is the DNS name of the generated Service inside the
+	// cluster, like <name>.<namespace>.svc
+	ServiceDNSName string `json:"serviceDNSName,omitempty"`
+	// URL is where the Ingress serves the MyResource outside of the cluster
+	URL string `json:"url,omitempty"`
+	// LastError is the message of the last failed reconcile, cleared
+	// once a reconcile succeeds
+	LastError string `json:"lastError,omitempty"`
+}
+
+// MyResourceConditionType is the type of a MyResource condition
+// +kubebuilder:validation:Enum=Ready;Progressing;Degraded
+type MyResourceConditionType string
+
+// MyResourceCondition describes the state of a MyResource at a certain point
+type MyResourceCondition struct {
+	// Type of the condition
+	Type MyResourceConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown
+	// +kubebuilder:validation:Enum=True;False;Unknown
+	Status core_v1.ConditionStatus `json:"status"`
+	// LastTransitionTime is the last time the condition changed status
+	LastTransitionTime meta_v1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a one-word CamelCase reason for the last transition
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable description of the last transition
+	Message string `json:"message,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// MyResourceList is a list of MyResource resources
+type MyResourceList struct {
+	meta_v1.TypeMeta `json:",inline"`
+	meta_v1.ListMeta `json:"metadata"`
+
+	Items []MyResource `json:"items"`
+}
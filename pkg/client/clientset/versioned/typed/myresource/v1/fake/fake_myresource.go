@@ -100,6 +100,18 @@ func (c *FakeMyResources) Update(myResource *myresource_v1.MyResource) (result *
 	return obj.(*myresource_v1.MyResource), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeMyResources) UpdateStatus(myResource *myresource_v1.MyResource) (*myresource_v1.MyResource, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(myresourcesResource, "status", c.ns, myResource), &myresource_v1.MyResource{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*myresource_v1.MyResource), err
+}
+
 // Delete takes name of the myResource and deletes it. Returns an error if one occurs.
 func (c *FakeMyResources) Delete(name string, options *v1.DeleteOptions) error {
 	_, err := c.Fake.
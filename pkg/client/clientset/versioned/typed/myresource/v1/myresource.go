@@ -37,6 +37,7 @@ type MyResourcesGetter interface {
 type MyResourceInterface interface {
 	Create(*v1.MyResource) (*v1.MyResource, error)
 	Update(*v1.MyResource) (*v1.MyResource, error)
+	UpdateStatus(*v1.MyResource) (*v1.MyResource, error)
 	Delete(name string, options *meta_v1.DeleteOptions) error
 	DeleteCollection(options *meta_v1.DeleteOptions, listOptions meta_v1.ListOptions) error
 	Get(name string, options meta_v1.GetOptions) (*v1.MyResource, error)
@@ -120,6 +121,22 @@ func (c *myResources) Update(myResource *v1.MyResource) (result *v1.MyResource,
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+
+func (c *myResources) UpdateStatus(myResource *v1.MyResource) (result *v1.MyResource, err error) {
+	result = &v1.MyResource{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("myresources").
+		Name(myResource.Name).
+		SubResource("status").
+		Body(myResource).
+		Do().
+		Into(result)
+	return
+}
+
 // Delete takes name of the myResource and deletes it. Returns an error if one occurs.
 func (c *myResources) Delete(name string, options *meta_v1.DeleteOptions) error {
 	return c.client.Delete().
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"k8s-controller-custom-resource/jsonpatch"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	apiv1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+)
+
+// diffManagedFields returns the patch operations for the labels and the spec
+// of the live child object, other metadata is owned by the API server or others
+func diffManagedFields(desired, live metav1.Object) ([]jsonpatch.Operation, error) {
+	desiredFields, err := managedFields(desired)
+	if err != nil {
+		return nil, err
+	}
+	liveFields, err := managedFields(live)
+	if err != nil {
+		return nil, err
+	}
+	return jsonpatch.DiffSubset("", desiredFields, liveFields), nil
+}
+
+func managedFields(child metav1.Object) (map[string]interface{}, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(child)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s: \n%v", child.GetName(), err)
+	}
+	metadata := map[string]interface{}{}
+	if labels, ok := object["metadata"].(map[string]interface{})["labels"]; ok {
+		metadata["labels"] = labels
+	}
+	return map[string]interface{}{"metadata": metadata, "spec": object["spec"]}, nil
+}
+
+// diffChild returns the patch operations bringing the live child object to
+// the desired state
+func diffChild(desired, live metav1.Object) ([]jsonpatch.Operation, error) {
+	operations, err := diffManagedFields(desired, live)
+	if err != nil {
+		return nil, err
+	}
+	switch desiredChild := desired.(type) {
+	case *apiv1.Service:
+		operations = append(operations, serviceTypeChange(desiredChild, live.(*apiv1.Service))...)
+	case *extensionsv1beta1.Ingress:
+		operations = append(operations, ingressChange(desiredChild, live.(*extensionsv1beta1.Ingress))...)
+	}
+	return operations, nil
+}
+
+// patchDrift patches the fields of a child object which drifted from the
+// desired state and adopts it when it is an orphan. The first attempt works
+// on a copy of the cached object, after a conflict get reads the latest one.
+// It returns the patched object, or the live one if it was up to date
+func patchDrift(resource *v1.MyResource, kind string, desired, cached metav1.Object,
+	get func() (metav1.Object, error), patch func(data []byte) (metav1.Object, error)) (metav1.Object, bool, error) {
+	// the cached object is shared with the informer, never modify it
+	live := cached.(runtime.Object).DeepCopyObject().(metav1.Object)
+	var result metav1.Object
+	patched := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Retrieve the latest version after a conflict
+		// RetryOnConflict uses exponential backoff to avoid exhausting the apiserver
+		if live == nil {
+			var getErr error
+			live, getErr = get()
+			if getErr != nil {
+				return fmt.Errorf("failed to get latest version of %s: \n%v", kind, getErr)
+			}
+		}
+		// take over a child created before owner references were set,
+		// but never touch one that belongs to something else
+		adopted, claimErr := claimObject(resource, live)
+		if claimErr != nil {
+			return claimErr
+		}
+		operations, diffErr := diffChild(desired, live)
+		if diffErr != nil {
+			return diffErr
+		}
+		if adopted {
+			operations = append(operations, jsonpatch.Operation{
+				Op:    "add",
+				Path:  "/metadata/ownerReferences",
+				Value: live.GetOwnerReferences(),
+			})
+		}
+		if len(operations) == 0 {
+			log.Infof("%s (%s/%s) is up to date", kind, resource.Namespace, live.GetName())
+			result = live
+			return nil
+		}
+
+		// the resource version makes the patch fail with a conflict when
+		// the child changed since it was compared
+		operations = append(operations, jsonpatch.Operation{
+			Op:    "add",
+			Path:  "/metadata/resourceVersion",
+			Value: live.GetResourceVersion(),
+		})
+		data, marshalErr := json.Marshal(operations)
+		if marshalErr != nil {
+			return marshalErr
+		}
+		log.Infof("Patching drifted %s (%s/%s): %s", strings.ToLower(kind), resource.Namespace, live.GetName(), data)
+		var patchErr error
+		result, patchErr = patch(data)
+		if patchErr != nil {
+			live = nil
+			return patchErr
+		}
+		patched = true
+		return nil
+	})
+	return result, patched, err
+}
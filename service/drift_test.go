@@ -0,0 +1,23 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestDiffDeploymentIgnoresServerDefaults(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	live := createHttpServiceSpec(resource)
+	live.ResourceVersion = "42"
+	live.Annotations = map[string]string{"deployment.kubernetes.io/revision": "1"}
+	live.Spec.RevisionHistoryLimit = int32Ptr(10)
+	live.Spec.Template.Spec.RestartPolicy = apiv1.RestartPolicyAlways
+	live.Spec.Template.Spec.Containers[0].TerminationMessagePolicy = apiv1.TerminationMessageReadFile
+	live.Spec.Template.Spec.Containers[0].TerminationMessagePath = "/dev/termination-log"
+
+	operations, err := diffManagedFields(createHttpServiceSpec(resource), live)
+	assert.Nil(t, err)
+	assert.Empty(t, operations)
+}
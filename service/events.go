@@ -0,0 +1,31 @@
+package service
+
+import (
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// the reasons of the events recorded on MyResources, shown by
+// kubectl describe myresource
+const (
+	ReasonCreated      = "Created"
+	ReasonUpdated      = "Updated"
+	ReasonDeleted      = "Deleted"
+	ReasonFailedCreate = "FailedCreate"
+	ReasonFailedUpdate = "FailedUpdate"
+	ReasonFailedDelete = "FailedDelete"
+	ReasonInvalidSpec  = "InvalidSpec"
+	ReasonDeprecated   = "DeprecatedField"
+)
+
+// recordFailure records a Warning event for a failed API call on a child
+// resource, with the reason of the API error like Forbidden or Conflict
+func (s *HttpService) recordFailure(resource *v1.MyResource, reason, action, kind, name string, err error) {
+	apiReason := errors.ReasonForError(err)
+	if apiReason == "" {
+		apiReason = "Unknown"
+	}
+	s.Recorder.Eventf(resource, apiv1.EventTypeWarning, reason, "Failed to %s %s %s (%s): %s",
+		action, kind, name, apiReason, err)
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	myresourcefake "k8s-controller-custom-resource/pkg/client/clientset/versioned/fake"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+// recordedEvents returns the events recorded since the last call
+func recordedEvents(s *HttpService) []string {
+	var events []string
+	for {
+		select {
+		case event := <-s.Recorder.(*record.FakeRecorder).Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestReconcileHttpRecordsEvents(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	client := fake.NewSimpleClientset()
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+
+	assert.Nil(t, s.ReconcileHttp(resource))
+	assert.Equal(t, []string{
+		"Normal Created Created Service demo",
+		"Normal Created Created Deployment demo",
+	}, recordedEvents(s))
+
+	// nothing is recorded while the children are up to date
+	assert.Nil(t, s.ReconcileHttp(resource))
+	assert.Empty(t, recordedEvents(s))
+
+	resource.Spec.Message = "httpd"
+	assert.Nil(t, s.ReconcileHttp(resource))
+	assert.Equal(t, []string{"Normal Updated Updated Deployment demo"}, recordedEvents(s))
+
+	resource, err := s.EnsureFinalizer(resource)
+	assert.Nil(t, err)
+	done, err := s.FinalizeHttp(resource)
+	assert.Nil(t, err)
+	assert.False(t, done)
+	assert.Equal(t, []string{
+		"Normal Deleted Deleted Service demo",
+		"Normal Deleted Deleted Deployment demo",
+	}, recordedEvents(s))
+}
+
+func TestReconcileHttpRecordsFailureReason(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"},
+			"demo", nil)
+	})
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+
+	assert.NotNil(t, s.ReconcileHttp(resource))
+	events := recordedEvents(s)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "Normal Created Created Service demo", events[0])
+		assert.Contains(t, events[1], "Warning FailedCreate Failed to create Deployment demo (Forbidden):")
+	}
+}
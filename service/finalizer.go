@@ -0,0 +1,331 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"k8s-controller-custom-resource/jsonpatch"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// RetainedAnnotation is set on the child resources released by a MyResource
+// with the Retain deletion policy, it holds the UID of that MyResource
+const RetainedAnnotation = "trstringer.com/retained-from"
+
+func hasFinalizer(resource *v1.MyResource) bool {
+	for _, finalizer := range resource.Finalizers {
+		if finalizer == v1.Finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func getDeletionPolicy(resource *v1.MyResource) v1.DeletionPolicy {
+	spec := resource.Spec.DeepCopy()
+	v1.SetDefaults_MyResourceSpec(spec)
+	return spec.DeletionPolicy
+}
+
+// EnsureFinalizer adds the Finalizer to the resource if it is missing
+func (s *HttpService) EnsureFinalizer(resource *v1.MyResource) (*v1.MyResource, error) {
+	if hasFinalizer(resource) {
+		return resource, nil
+	}
+	myResourceClient := s.myResources(resource.Namespace)
+	latest := resource.DeepCopy()
+	var result *v1.MyResource
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if latest == nil {
+			var getErr error
+			latest, getErr = myResourceClient.Get(resource.Name, metav1.GetOptions{})
+			if getErr != nil {
+				return getErr
+			}
+		}
+		if hasFinalizer(latest) {
+			result = latest
+			return nil
+		}
+		latest.Finalizers = append(latest.Finalizers, v1.Finalizer)
+		var updateErr error
+		result, updateErr = myResourceClient.Update(latest)
+		if updateErr != nil {
+			latest = nil
+		}
+		return updateErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add finalizer to myresource %s: \n%v", resource.Name, err)
+	}
+	return result, nil
+}
+
+// FinalizeHttp handles the child resources of a deleted resource according to
+// its DeletionPolicy and removes the Finalizer afterwards. It returns false
+// while the child resources are still being deleted
+func (s *HttpService) FinalizeHttp(resource *v1.MyResource) (bool, error) {
+	if !hasFinalizer(resource) {
+		return true, nil
+	}
+
+	policy := getDeletionPolicy(resource)
+	log.Infof("Finalizing myresource (%s) with deletion policy %s", resource.Name, policy)
+	switch policy {
+	case v1.DeletionPolicyDelete:
+		// delete every child at once, the finalizer stays until the
+		// caches no longer have any of them
+		gone := true
+		for _, deleteChild := range []func(*v1.MyResource) (bool, error){
+			s.deleteIngress, s.deleteService, s.deleteDeployment} {
+			childGone, err := deleteChild(resource)
+			if err != nil {
+				return false, err
+			}
+			gone = gone && childGone
+		}
+		if !gone {
+			return false, nil
+		}
+	case v1.DeletionPolicyRetain, v1.DeletionPolicyOrphan:
+		retain := policy == v1.DeletionPolicyRetain
+		if err := s.releaseDeployment(resource, retain); err != nil {
+			return false, err
+		}
+		if err := s.releaseService(resource, retain); err != nil {
+			return false, err
+		}
+		if err := s.releaseIngress(resource, retain); err != nil {
+			return false, err
+		}
+	default:
+		return false, fmt.Errorf("unknown deletion policy %s of myresource %s", policy, resource.Name)
+	}
+
+	return true, s.removeFinalizer(resource)
+}
+
+// deleteDeployment deletes the Deployment controlled by the resource in the
+// foreground, it returns true once the Deployment and its pods are gone
+func (s *HttpService) deleteDeployment(resource *v1.MyResource) (bool, error) {
+	deployment, err := s.DeploymentLister.Deployments(resource.Namespace).Get(resource.Name)
+	if errors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get Deployment %s: \n%v", resource.Name, err)
+	}
+	return s.deleteChild(resource, "Deployment", deployment, metav1.DeletePropagationForeground,
+		s.deployments(resource.Namespace).Delete)
+}
+
+// deleteChild deletes a cached child controlled by the resource, the UID
+// precondition keeps it from deleting a newer child of the same name. It
+// returns true once the child is gone or when it belongs to something else
+func (s *HttpService) deleteChild(resource *v1.MyResource, kind string, cached metav1.Object,
+	propagation metav1.DeletionPropagation, deleteFunc func(string, *metav1.DeleteOptions) error) (bool, error) {
+	if controllerRef := metav1.GetControllerOf(cached); controllerRef == nil || controllerRef.UID != resource.UID {
+		// never delete what this resource does not own
+		return true, nil
+	}
+	if cached.GetDeletionTimestamp() != nil {
+		log.Infof("Waiting for %s (%s/%s) to be deleted", strings.ToLower(kind), resource.Namespace, resource.Name)
+		return false, nil
+	}
+
+	log.Infof("Deleting %s (%s/%s)", strings.ToLower(kind), resource.Namespace, resource.Name)
+	uid := cached.GetUID()
+	err := deleteFunc(resource.Name, &metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+		Preconditions:     &metav1.Preconditions{UID: &uid},
+	})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		s.recordFailure(resource, ReasonFailedDelete, "delete", kind, resource.Name, err)
+		return false, fmt.Errorf("failed to delete %s %s: \n%v", kind, resource.Name, err)
+	}
+	s.Recorder.Eventf(resource, apiv1.EventTypeNormal, ReasonDeleted, "Deleted %s %s", kind, resource.Name)
+	return false, nil
+}
+
+// releaseDeployment removes the owner reference of the resource from its
+// Deployment so that the garbage collector keeps it, a retained Deployment
+// is annotated so that it is never adopted again
+func (s *HttpService) releaseDeployment(resource *v1.MyResource, retain bool) error {
+	deploymentsClient := s.deployments(resource.Namespace)
+	cached, err := s.DeploymentLister.Deployments(resource.Namespace).Get(resource.Name)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get Deployment %s: \n%v", resource.Name, err)
+	}
+	return releaseChild(resource, "Deployment", retain, cached.DeepCopy(),
+		func() (metav1.Object, error) {
+			return deploymentsClient.Get(resource.Name, metav1.GetOptions{})
+		},
+		func(data []byte) error {
+			_, err := deploymentsClient.Patch(resource.Name, types.JSONPatchType, data)
+			return err
+		})
+}
+
+// releaseService releases the Service of the resource like its Deployment
+func (s *HttpService) releaseService(resource *v1.MyResource, retain bool) error {
+	servicesClient := s.services(resource.Namespace)
+	cached, err := s.ServiceLister.Services(resource.Namespace).Get(resource.Name)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get Service %s: \n%v", resource.Name, err)
+	}
+	return releaseChild(resource, "Service", retain, cached.DeepCopy(),
+		func() (metav1.Object, error) {
+			return servicesClient.Get(resource.Name, metav1.GetOptions{})
+		},
+		func(data []byte) error {
+			_, err := servicesClient.Patch(resource.Name, types.JSONPatchType, data)
+			return err
+		})
+}
+
+// releaseIngress releases the Ingress of the resource like its Deployment
+func (s *HttpService) releaseIngress(resource *v1.MyResource, retain bool) error {
+	ingressesClient := s.ingresses(resource.Namespace)
+	cached, err := s.IngressLister.Ingresses(resource.Namespace).Get(resource.Name)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get Ingress %s: \n%v", resource.Name, err)
+	}
+	return releaseChild(resource, "Ingress", retain, cached.DeepCopy(),
+		func() (metav1.Object, error) {
+			return ingressesClient.Get(resource.Name, metav1.GetOptions{})
+		},
+		func(data []byte) error {
+			_, err := ingressesClient.Patch(resource.Name, types.JSONPatchType, data)
+			return err
+		})
+}
+
+// releaseChild removes the owner reference of the resource from a cached
+// child with a JSON patch, the child is fetched again after a conflict. A
+// patch keeps the fields the client types don't know, which an update of
+// the decoded child would drop
+func releaseChild(resource *v1.MyResource, kind string, retain bool, child metav1.Object,
+	get func() (metav1.Object, error), patch func(data []byte) error) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if child == nil {
+			var getErr error
+			child, getErr = get()
+			if errors.IsNotFound(getErr) {
+				return nil
+			}
+			if getErr != nil {
+				return getErr
+			}
+		}
+
+		// an empty list rather than none, so that the patch replaces them
+		ownerReferences := []metav1.OwnerReference{}
+		for _, ownerReference := range child.GetOwnerReferences() {
+			if ownerReference.UID != resource.UID {
+				ownerReferences = append(ownerReferences, ownerReference)
+			}
+		}
+		if len(ownerReferences) == len(child.GetOwnerReferences()) {
+			return nil
+		}
+		operations := []jsonpatch.Operation{{Op: "replace", Path: "/metadata/ownerReferences", Value: ownerReferences}}
+		if retain {
+			if child.GetAnnotations() == nil {
+				operations = append(operations, jsonpatch.Operation{
+					Op:    "add",
+					Path:  "/metadata/annotations",
+					Value: map[string]string{RetainedAnnotation: string(resource.UID)},
+				})
+			} else {
+				operations = append(operations, jsonpatch.Operation{
+					Op:    "add",
+					Path:  "/metadata/annotations/" + jsonpatch.EscapePathKey(RetainedAnnotation),
+					Value: string(resource.UID),
+				})
+			}
+		}
+		// the resource version makes the patch fail with a conflict when
+		// the child changed since it was read
+		operations = append(operations, jsonpatch.Operation{
+			Op:    "add",
+			Path:  "/metadata/resourceVersion",
+			Value: child.GetResourceVersion(),
+		})
+		data, marshalErr := json.Marshal(operations)
+		if marshalErr != nil {
+			return marshalErr
+		}
+
+		log.Infof("Releasing %s (%s) from myresource: %s", strings.ToLower(kind), resource.Name, data)
+		patchErr := patch(data)
+		if patchErr != nil {
+			child = nil
+		}
+		return patchErr
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release %s %s: \n%v", kind, resource.Name, err)
+	}
+	return nil
+}
+
+// removeFinalizer removes the Finalizer so that the resource can be deleted
+func (s *HttpService) removeFinalizer(resource *v1.MyResource) error {
+	myResourceClient := s.myResources(resource.Namespace)
+	latest := resource.DeepCopy()
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if latest == nil {
+			var getErr error
+			latest, getErr = myResourceClient.Get(resource.Name, metav1.GetOptions{})
+			if errors.IsNotFound(getErr) {
+				return nil
+			}
+			if getErr != nil {
+				return getErr
+			}
+		}
+
+		var finalizers []string
+		for _, finalizer := range latest.Finalizers {
+			if finalizer != v1.Finalizer {
+				finalizers = append(finalizers, finalizer)
+			}
+		}
+		if len(finalizers) == len(latest.Finalizers) {
+			return nil
+		}
+		latest.Finalizers = finalizers
+		_, updateErr := myResourceClient.Update(latest)
+		if errors.IsNotFound(updateErr) {
+			return nil
+		}
+		if updateErr != nil {
+			latest = nil
+		}
+		return updateErr
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove finalizer from myresource %s: \n%v", resource.Name, err)
+	}
+	log.Infof("Removed finalizer from myresource (%s)", resource.Name)
+	return nil
+}
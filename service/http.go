@@ -2,81 +2,135 @@ package service
 
 import (
 	"fmt"
+	"strconv"
+
 	log "github.com/Sirupsen/logrus"
 	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
-	"k8s-controller-custom-resource/util"
 	appsv1 "k8s.io/api/apps/v1"
 	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/util/retry"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	appstyped "k8s.io/client-go/kubernetes/typed/apps/v1"
+	coretyped "k8s.io/client-go/kubernetes/typed/core/v1"
+	extensionstyped "k8s.io/client-go/kubernetes/typed/extensions/v1beta1"
+	appslister_v1 "k8s.io/client-go/listers/apps/v1"
+	corelister_v1 "k8s.io/client-go/listers/core/v1"
+	extensionslister_v1beta1 "k8s.io/client-go/listers/extensions/v1beta1"
+	"k8s.io/client-go/tools/record"
+
+	myresourceclientset "k8s-controller-custom-resource/pkg/client/clientset/versioned"
+	myresourcetyped "k8s-controller-custom-resource/pkg/client/clientset/versioned/typed/myresource/v1"
 )
 
+// HttpService reconciles the child resources of MyResources through the
+// given clients, every child lives in the namespace of its MyResource.
+// Deployments are read from the DeploymentLister, the API server is only
+// asked again after a conflict or when the cache misses a child, the same
+// goes for Services. The changes to the children are recorded as events on
+// the MyResource
+type HttpService struct {
+	Client           kubernetes.Interface
+	MyResourceClient myresourceclientset.Interface
+	DeploymentLister appslister_v1.DeploymentLister
+	ServiceLister    corelister_v1.ServiceLister
+	IngressLister    extensionslister_v1beta1.IngressLister
+	Recorder         record.EventRecorder
+}
+
+// NewHttpService returns a HttpService using the given clients, listers
+// backed by shared Deployment, Service and Ingress informers and an event
+// recorder
+func NewHttpService(client kubernetes.Interface, myResourceClient myresourceclientset.Interface,
+	deploymentLister appslister_v1.DeploymentLister, serviceLister corelister_v1.ServiceLister,
+	ingressLister extensionslister_v1beta1.IngressLister, recorder record.EventRecorder) *HttpService {
+	return &HttpService{
+		Client:           client,
+		MyResourceClient: myResourceClient,
+		DeploymentLister: deploymentLister,
+		ServiceLister:    serviceLister,
+		IngressLister:    ingressLister,
+		Recorder:         recorder,
+	}
+}
+
+func (s *HttpService) deployments(namespace string) appstyped.DeploymentInterface {
+	return s.Client.AppsV1().Deployments(namespace)
+}
+
+func (s *HttpService) services(namespace string) coretyped.ServiceInterface {
+	return s.Client.CoreV1().Services(namespace)
+}
+
+func (s *HttpService) ingresses(namespace string) extensionstyped.IngressInterface {
+	return s.Client.ExtensionsV1beta1().Ingresses(namespace)
+}
+
+func (s *HttpService) myResources(namespace string) myresourcetyped.MyResourceInterface {
+	return s.MyResourceClient.TrstringerV1().MyResources(namespace)
+}
+
 func int32Ptr(i int32) *int32 { return &i }
 
-func getHttpEnvVariable(value int32) ([]apiv1.EnvVar) {
-	var enableGet = "true"
-	var enablePut = "false"
-	switch value {
-	case 2:
-		enableGet = "false"
-		enablePut = "true"
-	case 3:
-		enableGet = "true"
-		enablePut = "true"
-	case 4:
-		enableGet = "false"
-		enablePut = "false"
-	default:
-		enableGet = "true"
-		enablePut = "false"
-	}
-	return []apiv1.EnvVar {
-		{
-			Name: "ENABLE_GET",
-			Value: enableGet,
-		},
-		{
-			Name: "ENABLE_PUT",
-			Value: enablePut,
-		},
+// getHttpEnvVariable returns an ENABLE_<METHOD> env var for every supported
+// method, set to true for the methods of the spec
+func getHttpEnvVariable(spec *v1.HTTPSpec) []apiv1.EnvVar {
+	enabled := map[v1.HTTPMethod]bool{}
+	for _, method := range spec.Methods {
+		enabled[method] = true
+	}
+	env := make([]apiv1.EnvVar, 0, len(v1.HTTPMethods))
+	for _, method := range v1.HTTPMethods {
+		env = append(env, apiv1.EnvVar{
+			Name:  "ENABLE_" + string(method),
+			Value: strconv.FormatBool(enabled[method]),
+		})
 	}
+	return env
 }
 
-func createHttpServiceSpec(resource *v1.MyResource) (*appsv1.Deployment) {
-	image := resource.Spec.Message
-	env := getHttpEnvVariable(*resource.Spec.SomeValue)
+// createHttpServiceSpec returns the desired Deployment of the resource, the
+// omitted fields of its spec take their defaults
+func createHttpServiceSpec(resource *v1.MyResource) *appsv1.Deployment {
+	spec := resource.Spec.DeepCopy()
+	v1.SetDefaults_MyResourceSpec(spec)
+	// the user env vars come last so that they can refer to the managed ones
+	env := append(getHttpEnvVariable(spec.EffectiveHTTP()), spec.Env...)
 
 	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: resource.Name,
+			Name:            resource.Name,
+			Namespace:       resource.Namespace,
+			Labels:          childLabels(resource),
+			OwnerReferences: []metav1.OwnerReference{newOwnerReference(resource)},
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: int32Ptr(1),
+			Replicas: spec.Replicas,
 			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app": "demo",
-				},
+				MatchLabels: selectorLabels(resource),
 			},
 			Template: apiv1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app": "demo",
-					},
+					Labels: podLabels(resource),
 				},
 				Spec: apiv1.PodSpec{
 					Containers: []apiv1.Container{
 						{
-							Name:  "web",
-							Image: image,
+							Name:            "web",
+							Image:           spec.Image,
+							ImagePullPolicy: spec.ImagePullPolicy,
 							Ports: []apiv1.ContainerPort{
 								{
 									Name:          "http",
 									Protocol:      apiv1.ProtocolTCP,
-									ContainerPort: 8888,
+									ContainerPort: spec.ContainerPort,
 								},
 							},
-							Env: env,
+							Env:       env,
+							EnvFrom:   spec.EnvFrom,
+							Resources: spec.Resources,
 						},
 					},
 				},
@@ -85,60 +139,123 @@ func createHttpServiceSpec(resource *v1.MyResource) (*appsv1.Deployment) {
 	}
 }
 
-func CreateHttp(obj interface{}) {
-	log.Infof("Create http service")
-	deploymentsClient := util.GetDeploymentClient()
-	myResource := obj.(*v1.MyResource)
-
-	executingDeployment, err := deploymentsClient.Get(myResource.Name, metav1.GetOptions{})
-
-	if err == nil {
-		log.Infof("Pods (%s) already created", myResource.Name)
-		log.Infof("Pods information:\n%v", executingDeployment)
-	} else {
+// ReconcileHttp converges the Deployment of the resource to the desired
+// state, creating it when it is missing and updating it otherwise
+func (s *HttpService) ReconcileHttp(myResource *v1.MyResource) error {
+	if !s.checkSpec(myResource) {
+		return nil
+	}
+	if err := s.reconcileService(myResource); err != nil {
+		s.writeStatus(myResource, s.cachedDeployment(myResource), err)
+		return err
+	}
+	if err := s.reconcileIngress(myResource); err != nil {
+		s.writeStatus(myResource, s.cachedDeployment(myResource), err)
+		return err
+	}
+	existing, err := s.DeploymentLister.Deployments(myResource.Namespace).Get(myResource.Name)
+	if errors.IsNotFound(err) {
+		// the cache may not have seen the Deployment created by the last
+		// reconcile yet, so only create it when the API server agrees
+		existing, err = s.deployments(myResource.Namespace).Get(myResource.Name, metav1.GetOptions{})
 		if errors.IsNotFound(err) {
-			log.Infof("Creating deployment (%s)", myResource.Name)
-			deploymentConfig := createHttpServiceSpec(myResource)
-			result, err := deploymentsClient.Create(deploymentConfig)
-			if err != nil {
-				panic(err)
-			}
-			log.Infof("Created deployment %s", result.GetObjectMeta().GetName())
-		} else {
-			log.Errorf("Failed to query resource (%s)", myResource.Name)
-			panic(err)
+			return s.createHttp(myResource)
 		}
 	}
+	if err != nil {
+		log.Errorf("Failed to query resource (%s/%s)", myResource.Namespace, myResource.Name)
+		s.writeStatus(myResource, nil, err)
+		return fmt.Errorf("failed to get Deployment %s: \n%v", myResource.Name, err)
+	}
+	selector := &metav1.LabelSelector{MatchLabels: selectorLabels(myResource)}
+	if !equality.Semantic.DeepEqual(existing.Spec.Selector, selector) {
+		return s.recreateHttp(myResource, existing)
+	}
+	return s.updateHttp(myResource, existing)
 }
 
-func UpdateHttp(objOld interface{}, objNew interface{}) {
-	deploymentsClient := util.GetDeploymentClient()
-	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		// Retrieve the latest version of Deployment before attempting update
-		// RetryOnConflict uses exponential backoff to avoid exhausting the apiserver
-		result, getErr := deploymentsClient.Get(objOld.(*v1.MyResource).Name, metav1.GetOptions{})
-		if getErr != nil {
-			panic(fmt.Errorf("failed to get latest version of Deployment: \n%v", getErr))
-		}
-		env := getHttpEnvVariable(*(objNew.(*v1.MyResource).Spec.SomeValue))
-		log.Infof("Updated env value: \n%v", env)
-		result.Spec.Template.Spec.Containers[0].Env = env
-		_, updateErr := deploymentsClient.Update(result)
-		return updateErr
+// recreateHttp replaces a Deployment whose selector differs from the desired
+// one, like the shared selector of Deployments from older controller versions,
+// since the selector of a Deployment can not be updated
+func (s *HttpService) recreateHttp(myResource *v1.MyResource, existing *appsv1.Deployment) error {
+	if _, err := claimObject(myResource, existing.DeepCopy()); err != nil {
+		s.writeStatus(myResource, existing, err)
+		return err
+	}
+
+	log.Infof("Recreating deployment (%s/%s) with selector %v, it was %v", myResource.Namespace, myResource.Name,
+		selectorLabels(myResource), existing.Spec.Selector)
+	// the old pods are removed in the background by the garbage collector
+	// while the new Deployment starts its own
+	deletePolicy := metav1.DeletePropagationBackground
+	err := s.deployments(myResource.Namespace).Delete(existing.Name, &metav1.DeleteOptions{
+		PropagationPolicy: &deletePolicy,
+		Preconditions:     &metav1.Preconditions{UID: &existing.UID},
 	})
+	if err != nil && !errors.IsNotFound(err) {
+		s.recordFailure(myResource, ReasonFailedDelete, "delete", "Deployment", existing.Name, err)
+		s.writeStatus(myResource, existing, err)
+		return fmt.Errorf("failed to delete Deployment %s for recreation: \n%v", myResource.Name, err)
+	}
+	s.Recorder.Eventf(myResource, apiv1.EventTypeNormal, ReasonDeleted,
+		"Deleted Deployment %s to recreate it with a new selector", existing.Name)
+	return s.createHttp(myResource)
+}
 
-	if retryErr != nil {
-		panic(fmt.Errorf("update failed: \n%v", retryErr))
+func (s *HttpService) createHttp(myResource *v1.MyResource) error {
+	log.Infof("Creating deployment (%s/%s)", myResource.Namespace, myResource.Name)
+	deploymentsClient := s.deployments(myResource.Namespace)
+	deploymentConfig := createHttpServiceSpec(myResource)
+	result, err := deploymentsClient.Create(deploymentConfig)
+	if err != nil {
+		s.recordFailure(myResource, ReasonFailedCreate, "create", "Deployment", deploymentConfig.Name, err)
+		s.writeStatus(myResource, nil, err)
+		return fmt.Errorf("failed to create Deployment %s: \n%v", myResource.Name, err)
 	}
+	log.Infof("Created deployment %s", result.GetObjectMeta().GetName())
+	s.Recorder.Eventf(myResource, apiv1.EventTypeNormal, ReasonCreated, "Created Deployment %s", result.Name)
+	return s.UpdateStatus(myResource, result, nil)
 }
 
-func DeleteHttp(obj interface{}) {
-	deploymentsClient := util.GetDeploymentClient()
-	deletePolicy := metav1.DeletePropagationForeground
-	if err := deploymentsClient.Delete(obj.(*v1.MyResource).Name, &metav1.DeleteOptions{
-		PropagationPolicy: &deletePolicy,
-	}); err != nil {
-		panic(err)
+// updateHttp patches the fields of the Deployment which drifted from the
+// desired state, whether the resource changed or the Deployment was edited
+func (s *HttpService) updateHttp(myResource *v1.MyResource, existing *appsv1.Deployment) error {
+	deploymentsClient := s.deployments(myResource.Namespace)
+	result, patched, err := patchDrift(myResource, "Deployment", createHttpServiceSpec(myResource), existing,
+		func() (metav1.Object, error) {
+			return deploymentsClient.Get(myResource.Name, metav1.GetOptions{})
+		},
+		func(data []byte) (metav1.Object, error) {
+			return deploymentsClient.Patch(myResource.Name, types.JSONPatchType, data)
+		})
+	if err != nil {
+		s.recordFailure(myResource, ReasonFailedUpdate, "update", "Deployment", myResource.Name, err)
+		s.writeStatus(myResource, existing, err)
+		return fmt.Errorf("update failed: \n%v", err)
+	}
+	updatedDeployment := result.(*appsv1.Deployment)
+	if patched {
+		s.Recorder.Eventf(myResource, apiv1.EventTypeNormal, ReasonUpdated, "Updated Deployment %s", updatedDeployment.Name)
+	}
+	return s.UpdateStatus(myResource, updatedDeployment, nil)
+}
+
+// cachedDeployment returns the Deployment of the resource from the cache, so
+// that the status of a failed reconcile still shows its replicas, or nil when
+// it doesn't exist
+func (s *HttpService) cachedDeployment(myResource *v1.MyResource) *appsv1.Deployment {
+	deployment, err := s.DeploymentLister.Deployments(myResource.Namespace).Get(myResource.Name)
+	if err != nil {
+		return nil
+	}
+	return deployment
+}
+
+// writeStatus records a failed reconcile in the resource status, a failure
+// to write the status is only logged so that the reconcile error is kept
+func (s *HttpService) writeStatus(myResource *v1.MyResource, deployment *appsv1.Deployment, reconcileErr error) {
+	if err := s.UpdateStatus(myResource, deployment, reconcileErr); err != nil {
+		log.Errorf("Failed to update status of myresource (%s):\n%v", myResource.Name, err)
 	}
 }
 
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	myresourceclientset "k8s-controller-custom-resource/pkg/client/clientset/versioned"
+	myresourcefake "k8s-controller-custom-resource/pkg/client/clientset/versioned/fake"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	appslister_v1 "k8s.io/client-go/listers/apps/v1"
+	corelister_v1 "k8s.io/client-go/listers/core/v1"
+	extensionslister_v1beta1 "k8s.io/client-go/listers/extensions/v1beta1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// clientDeploymentLister reads through the fake client, like the cache of an
+// informer which is always in sync, the service only gets Deployments
+type clientDeploymentLister struct {
+	appslister_v1.DeploymentLister
+	client kubernetes.Interface
+}
+
+func (l clientDeploymentLister) Deployments(namespace string) appslister_v1.DeploymentNamespaceLister {
+	return clientDeploymentNamespaceLister{client: l.client, namespace: namespace}
+}
+
+type clientDeploymentNamespaceLister struct {
+	appslister_v1.DeploymentNamespaceLister
+	client    kubernetes.Interface
+	namespace string
+}
+
+func (l clientDeploymentNamespaceLister) Get(name string) (*appsv1.Deployment, error) {
+	return l.client.AppsV1().Deployments(l.namespace).Get(name, metav1.GetOptions{})
+}
+
+func newTestService(client kubernetes.Interface, myResourceClient myresourceclientset.Interface) *HttpService {
+	return NewHttpService(client, myResourceClient, clientDeploymentLister{client: client},
+		clientServiceLister{client: client}, clientIngressLister{client: client}, record.NewFakeRecorder(100))
+}
+
+func newNamespacedResource(namespace string, uid types.UID) *v1.MyResource {
+	someValue := int32(1)
+	return &v1.MyResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: namespace, UID: uid},
+		Spec:       v1.MyResourceSpec{Message: "nginx", SomeValue: &someValue},
+	}
+}
+
+func TestReconcileHttpUsesResourceNamespace(t *testing.T) {
+	teamA := newNamespacedResource("team-a", "uid-a")
+	teamB := newNamespacedResource("team-b", "uid-b")
+	client := fake.NewSimpleClientset()
+	s := newTestService(client, myresourcefake.NewSimpleClientset(teamA, teamB))
+
+	// same-named resources in two namespaces must not collide
+	assert.Nil(t, s.ReconcileHttp(teamA))
+	assert.Nil(t, s.ReconcileHttp(teamB))
+
+	for _, resource := range []*v1.MyResource{teamA, teamB} {
+		deployment, err := client.AppsV1().Deployments(resource.Namespace).Get("demo", metav1.GetOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, resource.Namespace, deployment.Namespace)
+		assert.Equal(t, resource.UID, metav1.GetControllerOf(deployment).UID)
+
+		latest, err := s.myResources(resource.Namespace).Get("demo", metav1.GetOptions{})
+		assert.Nil(t, err)
+		assert.NotEmpty(t, latest.Status.Conditions)
+	}
+
+	_, err := client.AppsV1().Deployments(apiv1.NamespaceDefault).Get("demo", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestFinalizeHttpOnlyTouchesResourceNamespace(t *testing.T) {
+	teamA := newNamespacedResource("team-a", "uid-a")
+	teamA.Spec.Ingress = &v1.IngressSpec{Host: "demo.example.com"}
+	teamB := newNamespacedResource("team-b", "uid-b")
+	teamB.Spec.Ingress = &v1.IngressSpec{Host: "demo.example.com"}
+	client := fake.NewSimpleClientset()
+	s := newTestService(client, myresourcefake.NewSimpleClientset(teamA, teamB))
+	assert.Nil(t, s.ReconcileHttp(teamA))
+	assert.Nil(t, s.ReconcileHttp(teamB))
+
+	teamA, err := s.EnsureFinalizer(teamA)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{v1.Finalizer}, teamA.Finalizers)
+
+	// the first call deletes the children, the second one sees them gone
+	done, err := s.FinalizeHttp(teamA)
+	assert.Nil(t, err)
+	assert.False(t, done)
+	done, err = s.FinalizeHttp(teamA)
+	assert.Nil(t, err)
+	assert.True(t, done)
+
+	_, err = client.AppsV1().Deployments("team-a").Get("demo", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+	_, err = client.CoreV1().Services("team-a").Get("demo", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+	_, err = client.ExtensionsV1beta1().Ingresses("team-a").Get("demo", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+	_, err = client.AppsV1().Deployments("team-b").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	_, err = client.CoreV1().Services("team-b").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	_, err = client.ExtensionsV1beta1().Ingresses("team-b").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+
+	latest, err := s.myResources("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, latest.Finalizers)
+}
+
+func TestFinalizeHttpRetainKeepsDeployment(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	resource.Spec.DeletionPolicy = v1.DeletionPolicyRetain
+	client := fake.NewSimpleClientset()
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+	assert.Nil(t, s.ReconcileHttp(resource))
+	resource, err := s.EnsureFinalizer(resource)
+	assert.Nil(t, err)
+
+	done, err := s.FinalizeHttp(resource)
+	assert.Nil(t, err)
+	assert.True(t, done)
+
+	deployment, err := client.AppsV1().Deployments("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, metav1.GetControllerOf(deployment))
+	assert.Equal(t, "uid-a", deployment.Annotations[RetainedAnnotation])
+}
+
+func TestFinalizeHttpRetainPatchesChildren(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	resource.Spec.DeletionPolicy = v1.DeletionPolicyRetain
+	client := fake.NewSimpleClientset()
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+	assert.Nil(t, s.ReconcileHttp(resource))
+	deployment, err := client.AppsV1().Deployments("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	deployment.Annotations = map[string]string{"deployment.kubernetes.io/revision": "1"}
+	_, err = client.AppsV1().Deployments("team-a").Update(deployment)
+	assert.Nil(t, err)
+	resource, err = s.EnsureFinalizer(resource)
+	assert.Nil(t, err)
+
+	// the children are released with a patch guarded by their resource
+	// version, never rewritten with the client types
+	client.ClearActions()
+	done, err := s.FinalizeHttp(resource)
+	assert.Nil(t, err)
+	assert.True(t, done)
+	for _, action := range client.Actions() {
+		assert.NotEqual(t, "update", action.GetVerb(), action.GetResource().Resource)
+		if patch, ok := action.(k8stesting.PatchAction); ok {
+			assert.Contains(t, string(patch.GetPatch()), `"path":"/metadata/resourceVersion"`)
+		}
+	}
+
+	deployment, err = client.AppsV1().Deployments("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, deployment.OwnerReferences)
+	assert.Equal(t, map[string]string{
+		"deployment.kubernetes.io/revision": "1",
+		RetainedAnnotation:                  "uid-a",
+	}, deployment.Annotations)
+}
+
+func TestCreateHttpServiceSpecLabels(t *testing.T) {
+	first := newNamespacedResource("team-a", "uid-a")
+	first.Labels = map[string]string{"team": "a", instanceLabel: "spoofed"}
+	second := newNamespacedResource("team-a", "uid-b")
+	second.Name = "other"
+
+	deployment := createHttpServiceSpec(first)
+	selector := deployment.Spec.Selector.MatchLabels
+	assert.Equal(t, "demo", selector[instanceLabel])
+	// the selector must not change when another MyResource adopts the Deployment
+	assert.NotContains(t, selector, uidLabel)
+	assert.Equal(t, "uid-a", deployment.Labels[uidLabel])
+	assert.NotContains(t, deployment.Spec.Template.Labels, uidLabel)
+	assert.NotContains(t, selector, "team")
+	assert.Equal(t, "a", deployment.Labels["team"])
+	assert.Equal(t, "a", deployment.Spec.Template.Labels["team"])
+	assert.Equal(t, controllerName, deployment.Spec.Template.Labels[managedByLabel])
+
+	// the pods of one resource never match the selector of another
+	podLabels := labels.Set(deployment.Spec.Template.Labels)
+	otherSelector := labels.SelectorFromSet(createHttpServiceSpec(second).Spec.Selector.MatchLabels)
+	assert.False(t, otherSelector.Matches(podLabels))
+	assert.True(t, labels.SelectorFromSet(selector).Matches(podLabels))
+}
+
+func TestReconcileHttpRecreatesLegacySelector(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	legacy := createHttpServiceSpec(resource)
+	legacy.OwnerReferences = nil
+	legacy.UID = "deployment-uid"
+	legacy.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "demo"}}
+	legacy.Spec.Template.Labels = map[string]string{"app": "demo"}
+	client := fake.NewSimpleClientset(legacy)
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	deployment, err := client.AppsV1().Deployments("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, selectorLabels(resource), deployment.Spec.Selector.MatchLabels)
+	assert.Equal(t, resource.UID, metav1.GetControllerOf(deployment).UID)
+}
+
+func TestReconcileHttpDoesNotRecreateForeignDeployment(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	foreign := createHttpServiceSpec(newNamespacedResource("team-a", "uid-other"))
+	client := fake.NewSimpleClientset(foreign)
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+
+	assert.NotNil(t, s.ReconcileHttp(resource))
+
+	deployment, err := client.AppsV1().Deployments("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "uid-other", string(metav1.GetControllerOf(deployment).UID))
+}
+
+func countPatches(client *fake.Clientset) int {
+	patches := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "patch" {
+			patches++
+		}
+	}
+	return patches
+}
+
+func TestReconcileHttpPatchesDrift(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	client := fake.NewSimpleClientset()
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	// an unchanged resource does not touch the Deployment
+	assert.Nil(t, s.ReconcileHttp(resource))
+	assert.Equal(t, 0, countPatches(client))
+
+	// the image follows spec.message
+	resource.Spec.Message = "httpd"
+	assert.Nil(t, s.ReconcileHttp(resource))
+	assert.Equal(t, 1, countPatches(client))
+	deployment, err := client.AppsV1().Deployments("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "httpd", deployment.Spec.Template.Spec.Containers[0].Image)
+
+	// hand edits of the Deployment are reverted, other labels are kept
+	deployment.Labels["team"] = "a"
+	deployment.Spec.Replicas = int32Ptr(5)
+	container := &deployment.Spec.Template.Spec.Containers[0]
+	container.Env = append(container.Env, apiv1.EnvVar{Name: "DEBUG", Value: "true"})
+	_, err = client.AppsV1().Deployments("team-a").Update(deployment)
+	assert.Nil(t, err)
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	deployment, err = client.AppsV1().Deployments("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), *deployment.Spec.Replicas)
+	assert.Equal(t, getHttpEnvVariable(v1.HTTPSpecFromSomeValue(1)), deployment.Spec.Template.Spec.Containers[0].Env)
+	assert.Equal(t, "a", deployment.Labels["team"])
+}
+
+func TestReconcileHttpAdoptsOrphanWithPatch(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	orphan := createHttpServiceSpec(resource)
+	orphan.OwnerReferences = nil
+	client := fake.NewSimpleClientset(orphan)
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	deployment, err := client.AppsV1().Deployments("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, resource.UID, metav1.GetControllerOf(deployment).UID)
+}
+
+func TestReconcileHttpAdoptsOrphanOfEarlierResource(t *testing.T) {
+	// the Deployment was orphaned by an earlier MyResource with the same name
+	orphan := createHttpServiceSpec(newNamespacedResource("team-a", "uid-old"))
+	orphan.OwnerReferences = nil
+	orphan.UID = "deployment-uid"
+	resource := newNamespacedResource("team-a", "uid-new")
+	client := fake.NewSimpleClientset(orphan)
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	// adopted in place, its pods keep running
+	for _, action := range client.Actions() {
+		assert.NotEqual(t, "delete", action.GetVerb())
+		assert.False(t, action.GetVerb() == "create" && action.GetResource().Resource == "deployments")
+	}
+	deployment, err := client.AppsV1().Deployments("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, orphan.UID, deployment.UID)
+	assert.Equal(t, resource.UID, metav1.GetControllerOf(deployment).UID)
+	assert.Equal(t, "uid-new", deployment.Labels[uidLabel])
+	assert.Equal(t, orphan.Spec.Template.Labels, deployment.Spec.Template.Labels)
+}
+
+func TestReconcileHttpReadsDeploymentFromCache(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	deployment := createHttpServiceSpec(resource)
+	service := createServiceSpec(resource)
+	client := fake.NewSimpleClientset(deployment, service)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.Nil(t, indexer.Add(deployment))
+	serviceIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.Nil(t, serviceIndexer.Add(service))
+	s := NewHttpService(client, myresourcefake.NewSimpleClientset(resource), appslister_v1.NewDeploymentLister(indexer),
+		corelister_v1.NewServiceLister(serviceIndexer),
+		extensionslister_v1beta1.NewIngressLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		record.NewFakeRecorder(100))
+
+	// an up to date Deployment in the cache costs no request at all
+	assert.Nil(t, s.ReconcileHttp(resource))
+	assert.Empty(t, client.Actions())
+
+	// the live Deployment is only fetched after a conflict
+	conflicts := 0
+	client.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts > 0 {
+			return false, nil, nil
+		}
+		conflicts++
+		return true, nil, errors.NewConflict(appsv1.Resource("deployments"), "demo", fmt.Errorf("stale"))
+	})
+	resource.Spec.Message = "httpd"
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	var verbs []string
+	for _, action := range client.Actions() {
+		verbs = append(verbs, action.GetVerb())
+	}
+	assert.Equal(t, []string{"patch", "get", "patch"}, verbs)
+	live, err := client.AppsV1().Deployments("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "httpd", live.Spec.Template.Spec.Containers[0].Image)
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"k8s-controller-custom-resource/jsonpatch"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	apiv1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// IngressClassAnnotation selects the ingress controller of an Ingress, the
+// Ingress API of the cluster has no ingressClassName field yet
+const IngressClassAnnotation = "kubernetes.io/ingress.class"
+
+// createIngressSpec returns the desired Ingress of the resource, it routes
+// the host and path of spec.ingress to the http port of the Service
+func createIngressSpec(resource *v1.MyResource) *extensionsv1beta1.Ingress {
+	spec := resource.Spec.DeepCopy()
+	v1.SetDefaults_MyResourceSpec(spec)
+
+	ingress := &extensionsv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            resource.Name,
+			Namespace:       resource.Namespace,
+			Labels:          childLabels(resource),
+			OwnerReferences: []metav1.OwnerReference{newOwnerReference(resource)},
+		},
+		Spec: extensionsv1beta1.IngressSpec{
+			Rules: []extensionsv1beta1.IngressRule{
+				{
+					Host: spec.Ingress.Host,
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: spec.Ingress.Path,
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: resource.Name,
+										ServicePort: intstr.FromString("http"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if spec.Ingress.IngressClassName != "" {
+		ingress.Annotations = map[string]string{IngressClassAnnotation: spec.Ingress.IngressClassName}
+	}
+	if spec.Ingress.TLSSecretName != "" {
+		ingress.Spec.TLS = []extensionsv1beta1.IngressTLS{
+			{Hosts: []string{spec.Ingress.Host}, SecretName: spec.Ingress.TLSSecretName},
+		}
+	}
+	return ingress
+}
+
+// ingressChange returns the extra patch operations of an Ingress for the
+// fields the subset diff leaves alone, the class annotation and a TLS
+// section which is no longer wanted
+func ingressChange(desired, live *extensionsv1beta1.Ingress) []jsonpatch.Operation {
+	var operations []jsonpatch.Operation
+	desiredClass := desired.Annotations[IngressClassAnnotation]
+	liveClass, found := live.Annotations[IngressClassAnnotation]
+	switch {
+	case desiredClass == "" && found:
+		operations = append(operations, jsonpatch.Operation{
+			Op:   "remove",
+			Path: "/metadata/annotations/" + jsonpatch.EscapePathKey(IngressClassAnnotation),
+		})
+	case desiredClass != "" && live.Annotations == nil:
+		operations = append(operations, jsonpatch.Operation{
+			Op:    "add",
+			Path:  "/metadata/annotations",
+			Value: map[string]string{IngressClassAnnotation: desiredClass},
+		})
+	case desiredClass != liveClass:
+		operations = append(operations, jsonpatch.Operation{
+			Op:    "add",
+			Path:  "/metadata/annotations/" + jsonpatch.EscapePathKey(IngressClassAnnotation),
+			Value: desiredClass,
+		})
+	}
+	if len(desired.Spec.TLS) == 0 && len(live.Spec.TLS) > 0 {
+		operations = append(operations, jsonpatch.Operation{Op: "remove", Path: "/spec/tls"})
+	}
+	return operations
+}
+
+// reconcileIngress creates or patches the Ingress of the resource while
+// spec.ingress is set and deletes it once the block is removed
+func (s *HttpService) reconcileIngress(myResource *v1.MyResource) error {
+	if myResource.Spec.Ingress == nil {
+		_, err := s.deleteIngress(myResource)
+		return err
+	}
+
+	ingressesClient := s.ingresses(myResource.Namespace)
+	desired := createIngressSpec(myResource)
+	existing, err := s.IngressLister.Ingresses(myResource.Namespace).Get(myResource.Name)
+	if errors.IsNotFound(err) {
+		existing, err = ingressesClient.Get(myResource.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			log.Infof("Creating ingress (%s/%s)", myResource.Namespace, myResource.Name)
+			if _, err := ingressesClient.Create(desired); err != nil {
+				s.recordFailure(myResource, ReasonFailedCreate, "create", "Ingress", desired.Name, err)
+				return fmt.Errorf("failed to create Ingress %s: \n%v", myResource.Name, err)
+			}
+			s.Recorder.Eventf(myResource, apiv1.EventTypeNormal, ReasonCreated, "Created Ingress %s", desired.Name)
+			return nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get Ingress %s: \n%v", myResource.Name, err)
+	}
+
+	_, patched, err := patchDrift(myResource, "Ingress", desired, existing,
+		func() (metav1.Object, error) {
+			return ingressesClient.Get(myResource.Name, metav1.GetOptions{})
+		},
+		func(data []byte) (metav1.Object, error) {
+			return ingressesClient.Patch(myResource.Name, types.JSONPatchType, data)
+		})
+	if err != nil {
+		s.recordFailure(myResource, ReasonFailedUpdate, "update", "Ingress", myResource.Name, err)
+		return fmt.Errorf("failed to update Ingress %s: \n%v", myResource.Name, err)
+	}
+	if patched {
+		s.Recorder.Eventf(myResource, apiv1.EventTypeNormal, ReasonUpdated, "Updated Ingress %s", myResource.Name)
+	}
+	return nil
+}
+
+// deleteIngress deletes the Ingress controlled by the resource, if any, it
+// returns true once the Ingress is gone
+func (s *HttpService) deleteIngress(myResource *v1.MyResource) (bool, error) {
+	ingress, err := s.IngressLister.Ingresses(myResource.Namespace).Get(myResource.Name)
+	if errors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get Ingress %s: \n%v", myResource.Name, err)
+	}
+	return s.deleteChild(myResource, "Ingress", ingress, metav1.DeletePropagationBackground,
+		s.ingresses(myResource.Namespace).Delete)
+}
+
+// ingressURL returns the URL of the Ingress controlled by the resource, or
+// nothing while the cache has no such Ingress
+func (s *HttpService) ingressURL(resource *v1.MyResource) string {
+	ingress, err := s.IngressLister.Ingresses(resource.Namespace).Get(resource.Name)
+	if err != nil {
+		return ""
+	}
+	if controllerRef := metav1.GetControllerOf(ingress); controllerRef == nil || controllerRef.UID != resource.UID {
+		return ""
+	}
+	if len(ingress.Spec.Rules) == 0 || ingress.Spec.Rules[0].HTTP == nil || len(ingress.Spec.Rules[0].HTTP.Paths) == 0 {
+		return ""
+	}
+	scheme := "http"
+	if len(ingress.Spec.TLS) > 0 {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, ingress.Spec.Rules[0].Host, ingress.Spec.Rules[0].HTTP.Paths[0].Path)
+}
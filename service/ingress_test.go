@@ -0,0 +1,123 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s-controller-custom-resource/jsonpatch"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	myresourcefake "k8s-controller-custom-resource/pkg/client/clientset/versioned/fake"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	extensionslister_v1beta1 "k8s.io/client-go/listers/extensions/v1beta1"
+)
+
+// clientIngressLister reads through the fake client like clientDeploymentLister
+type clientIngressLister struct {
+	extensionslister_v1beta1.IngressLister
+	client kubernetes.Interface
+}
+
+func (l clientIngressLister) Ingresses(namespace string) extensionslister_v1beta1.IngressNamespaceLister {
+	return clientIngressNamespaceLister{client: l.client, namespace: namespace}
+}
+
+type clientIngressNamespaceLister struct {
+	extensionslister_v1beta1.IngressNamespaceLister
+	client    kubernetes.Interface
+	namespace string
+}
+
+func (l clientIngressNamespaceLister) Get(name string) (*extensionsv1beta1.Ingress, error) {
+	return l.client.ExtensionsV1beta1().Ingresses(l.namespace).Get(name, metav1.GetOptions{})
+}
+
+func TestReconcileHttpCreatesIngress(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	resource.Spec.Ingress = &v1.IngressSpec{Host: "demo.example.com", IngressClassName: "nginx",
+		TLSSecretName: "demo-tls"}
+	client := fake.NewSimpleClientset()
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	ingress, err := client.ExtensionsV1beta1().Ingresses("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, resource.UID, metav1.GetControllerOf(ingress).UID)
+	assert.Equal(t, "nginx", ingress.Annotations[IngressClassAnnotation])
+	assert.Equal(t, []extensionsv1beta1.IngressTLS{{Hosts: []string{"demo.example.com"}, SecretName: "demo-tls"}},
+		ingress.Spec.TLS)
+	if assert.Len(t, ingress.Spec.Rules, 1) {
+		assert.Equal(t, "demo.example.com", ingress.Spec.Rules[0].Host)
+		assert.Equal(t, []extensionsv1beta1.HTTPIngressPath{{
+			Path:    "/",
+			Backend: extensionsv1beta1.IngressBackend{ServiceName: "demo", ServicePort: intstr.FromString("http")},
+		}}, ingress.Spec.Rules[0].HTTP.Paths)
+	}
+
+	latest, err := s.myResources("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://demo.example.com/", latest.Status.URL)
+}
+
+func TestReconcileHttpUpdatesAndDeletesIngress(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	resource.Spec.Ingress = &v1.IngressSpec{Host: "demo.example.com", IngressClassName: "nginx"}
+	client := fake.NewSimpleClientset()
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	resource.Spec.Ingress = &v1.IngressSpec{Host: "demo.example.org", Path: "/demo", IngressClassName: "traefik"}
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	ingress, err := client.ExtensionsV1beta1().Ingresses("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "traefik", ingress.Annotations[IngressClassAnnotation])
+	assert.Equal(t, "demo.example.org", ingress.Spec.Rules[0].Host)
+	assert.Equal(t, "/demo", ingress.Spec.Rules[0].HTTP.Paths[0].Path)
+	latest, err := s.myResources("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "http://demo.example.org/demo", latest.Status.URL)
+
+	// removing the block removes the Ingress and its URL
+	resource.Spec.Ingress = nil
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	_, err = client.ExtensionsV1beta1().Ingresses("team-a").Get("demo", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+	latest, err = s.myResources("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, latest.Status.URL)
+}
+
+func TestReconcileHttpKeepsForeignIngress(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	foreign := &extensionsv1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "team-a"}}
+	client := fake.NewSimpleClientset(foreign)
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	_, err := client.ExtensionsV1beta1().Ingresses("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+}
+
+func TestIngressChange(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	resource.Spec.Ingress = &v1.IngressSpec{Host: "demo.example.com"}
+	desired := createIngressSpec(resource)
+	live := desired.DeepCopy()
+	assert.Empty(t, ingressChange(desired, live))
+
+	// the subset diff keeps fields only set on the live Ingress
+	live.Annotations = map[string]string{IngressClassAnnotation: "nginx"}
+	live.Spec.TLS = []extensionsv1beta1.IngressTLS{{Hosts: []string{"demo.example.com"}, SecretName: "demo-tls"}}
+	assert.Equal(t, []jsonpatch.Operation{
+		{Op: "remove", Path: "/metadata/annotations/kubernetes.io~1ingress.class"},
+		{Op: "remove", Path: "/spec/tls"},
+	}, ingressChange(desired, live))
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+)
+
+// the standard labels set on every child resource, see
+// https://kubernetes.io/docs/concepts/overview/working-with-objects/common-labels/
+const (
+	nameLabel      = v1.NameLabel
+	instanceLabel  = v1.InstanceLabel
+	managedByLabel = "app.kubernetes.io/managed-by"
+	// uidLabel tells which MyResource a child belongs to. It is not part
+	// of the selector, which can't change, so that a new MyResource with
+	// the same name adopts the orphaned children of an earlier one
+	uidLabel = "trstringer.com/myresource-uid"
+
+	appName        = v1.AppName
+	controllerName = "myresource-controller"
+)
+
+// selectorLabels returns the labels selecting the pods of the resource,
+// they are unique per name and must never change for a Deployment
+func selectorLabels(resource *v1.MyResource) map[string]string {
+	return map[string]string{
+		nameLabel:     appName,
+		instanceLabel: resource.Name,
+	}
+}
+
+// podLabels returns the labels of the pods of the resource, which are the
+// labels of the resource itself plus the standard labels. They leave out
+// the uidLabel, so an adopted Deployment doesn't roll out its pods again
+func podLabels(resource *v1.MyResource) map[string]string {
+	labels := map[string]string{}
+	for key, value := range resource.Labels {
+		labels[key] = value
+	}
+	for key, value := range selectorLabels(resource) {
+		labels[key] = value
+	}
+	labels[managedByLabel] = controllerName
+	return labels
+}
+
+// childLabels returns the labels of the child resources of the resource,
+// the labels of its pods plus the uidLabel
+func childLabels(resource *v1.MyResource) map[string]string {
+	labels := podLabels(resource)
+	labels[uidLabel] = string(resource.UID)
+	return labels
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newOwnerReference makes the resource the controller of a child object, so
+// that the garbage collector removes the child once the resource is deleted
+func newOwnerReference(resource *v1.MyResource) metav1.OwnerReference {
+	// objects from the lister have no TypeMeta, so the kind is set explicitly
+	return *metav1.NewControllerRef(resource, v1.SchemeGroupVersion.WithKind("MyResource"))
+}
+
+// claimObject adopts a child object without controller by adding an owner
+// reference to the resource, it returns whether the object was changed and an
+// error if the object is controlled by something else or has been retained
+func claimObject(resource *v1.MyResource, object metav1.Object) (bool, error) {
+	controllerRef := metav1.GetControllerOf(object)
+	if controllerRef == nil {
+		if retainedFrom, ok := object.GetAnnotations()[RetainedAnnotation]; ok {
+			return false, fmt.Errorf("%s was retained from a deleted myresource (%s), remove it or its %s annotation",
+				object.GetName(), retainedFrom, RetainedAnnotation)
+		}
+		log.Infof("Adopting orphan (%s) for myresource (%s)", object.GetName(), resource.Name)
+		object.SetOwnerReferences(append(object.GetOwnerReferences(), newOwnerReference(resource)))
+		return true, nil
+	}
+	if controllerRef.UID != resource.UID {
+		return false, fmt.Errorf("%s is already controlled by %s %s", object.GetName(), controllerRef.Kind, controllerRef.Name)
+	}
+	return false, nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newOwnerTestResource() *v1.MyResource {
+	someValue := int32(1)
+	return &v1.MyResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default", UID: "uid-1"},
+		Spec:       v1.MyResourceSpec{Message: "nginx", SomeValue: &someValue},
+	}
+}
+
+func TestCreateHttpServiceSpecIsOwned(t *testing.T) {
+	deployment := createHttpServiceSpec(newOwnerTestResource())
+
+	controllerRef := metav1.GetControllerOf(deployment)
+	assert.NotNil(t, controllerRef)
+	assert.Equal(t, "trstringer.com/v1", controllerRef.APIVersion)
+	assert.Equal(t, "MyResource", controllerRef.Kind)
+	assert.Equal(t, "demo", controllerRef.Name)
+	assert.Equal(t, "uid-1", string(controllerRef.UID))
+	assert.True(t, *controllerRef.BlockOwnerDeletion)
+}
+
+func TestClaimObjectAdoptsOrphan(t *testing.T) {
+	resource := newOwnerTestResource()
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "demo"}}
+
+	adopted, err := claimObject(resource, deployment)
+	assert.Nil(t, err)
+	assert.True(t, adopted)
+	assert.Equal(t, resource.UID, metav1.GetControllerOf(deployment).UID)
+
+	// claiming again is a no-op
+	adopted, err = claimObject(resource, deployment)
+	assert.Nil(t, err)
+	assert.False(t, adopted)
+	assert.Len(t, deployment.OwnerReferences, 1)
+}
+
+func TestClaimObjectControlledByOther(t *testing.T) {
+	other := newOwnerTestResource()
+	other.UID = "uid-2"
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name:            "demo",
+		OwnerReferences: []metav1.OwnerReference{newOwnerReference(other)},
+	}}
+
+	adopted, err := claimObject(newOwnerTestResource(), deployment)
+	assert.NotNil(t, err)
+	assert.False(t, adopted)
+}
+
+func TestClaimObjectRefusesRetained(t *testing.T) {
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name:        "demo",
+		Annotations: map[string]string{RetainedAnnotation: "uid-0"},
+	}}
+
+	adopted, err := claimObject(newOwnerTestResource(), deployment)
+	assert.NotNil(t, err)
+	assert.False(t, adopted)
+	assert.Empty(t, deployment.OwnerReferences)
+}
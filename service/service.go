@@ -0,0 +1,130 @@
+package service
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"k8s-controller-custom-resource/jsonpatch"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// createServiceSpec returns the desired Service of the resource, it selects
+// the pods of the Deployment and forwards to their HTTP port
+func createServiceSpec(resource *v1.MyResource) *apiv1.Service {
+	spec := resource.Spec.DeepCopy()
+	v1.SetDefaults_MyResourceSpec(spec)
+
+	return &apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            resource.Name,
+			Namespace:       resource.Namespace,
+			Labels:          childLabels(resource),
+			OwnerReferences: []metav1.OwnerReference{newOwnerReference(resource)},
+		},
+		Spec: apiv1.ServiceSpec{
+			Type:     spec.Service.Type,
+			Selector: selectorLabels(resource),
+			Ports: []apiv1.ServicePort{
+				{
+					Name:       "http",
+					Protocol:   apiv1.ProtocolTCP,
+					Port:       spec.Service.Port,
+					TargetPort: intstr.FromInt(int(spec.ContainerPort)),
+					NodePort:   spec.Service.NodePort,
+				},
+			},
+		},
+	}
+}
+
+// serviceTypeChange returns the extra patch operations of a Service changing
+// its type. The node ports allocated for the old type are dropped with the
+// ports and the fields only valid for external types are removed
+func serviceTypeChange(desired, live *apiv1.Service) []jsonpatch.Operation {
+	if desired.Spec.Type == live.Spec.Type {
+		return nil
+	}
+	operations := []jsonpatch.Operation{{Op: "replace", Path: "/spec/ports", Value: desired.Spec.Ports}}
+	if desired.Spec.Type == apiv1.ServiceTypeClusterIP {
+		if live.Spec.ExternalTrafficPolicy != "" {
+			operations = append(operations, jsonpatch.Operation{Op: "remove", Path: "/spec/externalTrafficPolicy"})
+		}
+		if live.Spec.HealthCheckNodePort != 0 {
+			operations = append(operations, jsonpatch.Operation{Op: "remove", Path: "/spec/healthCheckNodePort"})
+		}
+	}
+	return operations
+}
+
+// reconcileService creates the Service of the resource when it is missing
+// and patches the fields which drifted from the desired state otherwise
+func (s *HttpService) reconcileService(myResource *v1.MyResource) error {
+	servicesClient := s.services(myResource.Namespace)
+	desired := createServiceSpec(myResource)
+	existing, err := s.ServiceLister.Services(myResource.Namespace).Get(myResource.Name)
+	if errors.IsNotFound(err) {
+		// the cache may not have seen the Service created by the last
+		// reconcile yet, so only create it when the API server agrees
+		existing, err = servicesClient.Get(myResource.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			log.Infof("Creating service (%s/%s)", myResource.Namespace, myResource.Name)
+			if _, err := servicesClient.Create(desired); err != nil {
+				s.recordFailure(myResource, ReasonFailedCreate, "create", "Service", desired.Name, err)
+				return fmt.Errorf("failed to create Service %s: \n%v", myResource.Name, err)
+			}
+			s.Recorder.Eventf(myResource, apiv1.EventTypeNormal, ReasonCreated, "Created Service %s", desired.Name)
+			return nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get Service %s: \n%v", myResource.Name, err)
+	}
+
+	_, patched, err := patchDrift(myResource, "Service", desired, existing,
+		func() (metav1.Object, error) {
+			return servicesClient.Get(myResource.Name, metav1.GetOptions{})
+		},
+		func(data []byte) (metav1.Object, error) {
+			return servicesClient.Patch(myResource.Name, types.JSONPatchType, data)
+		})
+	if err != nil {
+		s.recordFailure(myResource, ReasonFailedUpdate, "update", "Service", myResource.Name, err)
+		return fmt.Errorf("failed to update Service %s: \n%v", myResource.Name, err)
+	}
+	if patched {
+		s.Recorder.Eventf(myResource, apiv1.EventTypeNormal, ReasonUpdated, "Updated Service %s", myResource.Name)
+	}
+	return nil
+}
+
+// deleteService deletes the Service controlled by the resource, if any, it
+// returns true once the Service is gone
+func (s *HttpService) deleteService(myResource *v1.MyResource) (bool, error) {
+	service, err := s.ServiceLister.Services(myResource.Namespace).Get(myResource.Name)
+	if errors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get Service %s: \n%v", myResource.Name, err)
+	}
+	return s.deleteChild(myResource, "Service", service, metav1.DeletePropagationBackground,
+		s.services(myResource.Namespace).Delete)
+}
+
+// serviceDNSName returns the DNS name of the Service controlled by the
+// resource inside the cluster, or nothing while the cache has no such Service
+func (s *HttpService) serviceDNSName(resource *v1.MyResource) string {
+	service, err := s.ServiceLister.Services(resource.Namespace).Get(resource.Name)
+	if err != nil {
+		return ""
+	}
+	if controllerRef := metav1.GetControllerOf(service); controllerRef == nil || controllerRef.UID != resource.UID {
+		return ""
+	}
+	return fmt.Sprintf("%s.%s.svc", service.Name, service.Namespace)
+}
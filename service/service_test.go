@@ -0,0 +1,164 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s-controller-custom-resource/jsonpatch"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	myresourcefake "k8s-controller-custom-resource/pkg/client/clientset/versioned/fake"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	corelister_v1 "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// clientServiceLister reads through the fake client like clientDeploymentLister
+type clientServiceLister struct {
+	corelister_v1.ServiceLister
+	client kubernetes.Interface
+}
+
+func (l clientServiceLister) Services(namespace string) corelister_v1.ServiceNamespaceLister {
+	return clientServiceNamespaceLister{client: l.client, namespace: namespace}
+}
+
+type clientServiceNamespaceLister struct {
+	corelister_v1.ServiceNamespaceLister
+	client    kubernetes.Interface
+	namespace string
+}
+
+func (l clientServiceNamespaceLister) Get(name string) (*apiv1.Service, error) {
+	return l.client.CoreV1().Services(l.namespace).Get(name, metav1.GetOptions{})
+}
+
+func TestReconcileHttpCreatesClusterIPService(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	client := fake.NewSimpleClientset()
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	service, err := client.CoreV1().Services("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, resource.UID, metav1.GetControllerOf(service).UID)
+	assert.Equal(t, apiv1.ServiceTypeClusterIP, service.Spec.Type)
+	assert.Equal(t, selectorLabels(resource), service.Spec.Selector)
+	if assert.Len(t, service.Spec.Ports, 1) {
+		assert.Equal(t, int32(v1.DefaultServicePort), service.Spec.Ports[0].Port)
+		assert.Equal(t, intstr.FromInt(v1.DefaultContainerPort), service.Spec.Ports[0].TargetPort)
+	}
+
+	latest, err := s.myResources("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "demo.team-a.svc", latest.Status.ServiceDNSName)
+}
+
+func TestReconcileHttpPatchesServicePorts(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	client := fake.NewSimpleClientset()
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	resource.Spec.ContainerPort = 9090
+	resource.Spec.Service = &v1.ServiceSpec{Port: 8080}
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	service, err := client.CoreV1().Services("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	if assert.Len(t, service.Spec.Ports, 1) {
+		assert.Equal(t, int32(8080), service.Spec.Ports[0].Port)
+		assert.Equal(t, intstr.FromInt(9090), service.Spec.Ports[0].TargetPort)
+	}
+}
+
+func TestReconcileHttpSwitchesServiceType(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	resource.Spec.Service = &v1.ServiceSpec{Type: apiv1.ServiceTypeNodePort, NodePort: 30080}
+	client := fake.NewSimpleClientset()
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	service, err := client.CoreV1().Services("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, apiv1.ServiceTypeNodePort, service.Spec.Type)
+	assert.Equal(t, int32(30080), service.Spec.Ports[0].NodePort)
+
+	resource.Spec.Service = nil
+	assert.Nil(t, s.ReconcileHttp(resource))
+
+	service, err = client.CoreV1().Services("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, apiv1.ServiceTypeClusterIP, service.Spec.Type)
+}
+
+func TestReconcileHttpKeepsDeploymentStatusWhenServiceFails(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	client := fake.NewSimpleClientset()
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+	assert.Nil(t, s.ReconcileHttp(resource))
+	deployment, err := client.AppsV1().Deployments("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	deployment.Status = appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+	_, err = client.AppsV1().Deployments("team-a").UpdateStatus(deployment)
+	assert.Nil(t, err)
+
+	// a failed Service patch must not report the running Deployment as missing
+	client.PrependReactor("patch", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("connection refused")
+	})
+	resource.Spec.Service = &v1.ServiceSpec{Port: 8080}
+	assert.NotNil(t, s.ReconcileHttp(resource))
+
+	latest, err := s.myResources("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), latest.Status.AvailableReplicas)
+	assert.Equal(t, apiv1.ConditionTrue, findCondition(latest.Status, v1.MyResourceReady).Status)
+	assert.Equal(t, apiv1.ConditionTrue, findCondition(latest.Status, v1.MyResourceDegraded).Status)
+	assert.Contains(t, latest.Status.LastError, "connection refused")
+}
+
+func TestServiceTypeChangeDropsNodePorts(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	desired := createServiceSpec(resource)
+	live := desired.DeepCopy()
+	assert.Empty(t, serviceTypeChange(desired, live))
+
+	// a cluster IP Service must not keep the allocated node port, which the
+	// subset diff leaves alone since the desired port does not set it
+	live.Spec.Type = apiv1.ServiceTypeLoadBalancer
+	live.Spec.Ports[0].NodePort = 30080
+	live.Spec.ExternalTrafficPolicy = apiv1.ServiceExternalTrafficPolicyTypeLocal
+	live.Spec.HealthCheckNodePort = 30081
+	assert.Equal(t, []jsonpatch.Operation{
+		{Op: "replace", Path: "/spec/ports", Value: desired.Spec.Ports},
+		{Op: "remove", Path: "/spec/externalTrafficPolicy"},
+		{Op: "remove", Path: "/spec/healthCheckNodePort"},
+	}, serviceTypeChange(desired, live))
+}
+
+func TestFinalizeHttpRetainKeepsService(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	resource.Spec.DeletionPolicy = v1.DeletionPolicyRetain
+	client := fake.NewSimpleClientset()
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+	assert.Nil(t, s.ReconcileHttp(resource))
+	resource, err := s.EnsureFinalizer(resource)
+	assert.Nil(t, err)
+
+	done, err := s.FinalizeHttp(resource)
+	assert.Nil(t, err)
+	assert.True(t, done)
+
+	service, err := client.CoreV1().Services("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, metav1.GetControllerOf(service))
+	assert.Equal(t, "uid-a", service.Annotations[RetainedAnnotation])
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// imageReference matches the image references the container runtime
+// accepts, [registry[:port]/]name[:tag][@digest], with the grammar of
+// github.com/docker/distribution/reference
+var imageReference = func() *regexp.Regexp {
+	const (
+		nameComponent   = `[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*`
+		domainComponent = `(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])`
+		domain          = domainComponent + `(?:\.` + domainComponent + `)*(?::[0-9]+)?`
+		tag             = `[\w][\w.-]{0,127}`
+		digest          = `[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}`
+	)
+	return regexp.MustCompile(`^(?:` + domain + `/)?` + nameComponent + `(?:/` + nameComponent + `)*` +
+		`(?::` + tag + `)?(?:@` + digest + `)?$`)
+}()
+
+// maxImageNameLength is the longest repository name of an image reference
+const maxImageNameLength = 255
+
+// validateImage returns why the image reference can't be pulled
+func validateImage(image string, fldPath *field.Path) field.ErrorList {
+	if !imageReference.MatchString(image) {
+		return field.ErrorList{field.Invalid(fldPath, image,
+			"must be an image reference like registry.example.com/name:tag or name@sha256:<digest>")}
+	}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	if len(name) > maxImageNameLength {
+		return field.ErrorList{field.TooLong(fldPath, image, maxImageNameLength)}
+	}
+	return nil
+}
+
+// validateName returns why the children can't be named like the resource.
+// The name of the resource is the name of its Service, which has to be a
+// DNS-1035 label, and the value of the instance label, which limits it to
+// 63 characters as well
+func validateName(name string, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, msg := range validation.IsDNS1035Label(name) {
+		errs = append(errs, field.Invalid(fldPath, name, msg))
+	}
+	return errs
+}
+
+// validatePort returns an error when the port is out of range, 0 leaves
+// it unset
+func validatePort(port int32, fldPath *field.Path) field.ErrorList {
+	if port < 0 || port > 65535 {
+		return field.ErrorList{field.Invalid(fldPath, port, "must be between 1 and 65535, or 0 to leave it unset")}
+	}
+	return nil
+}
+
+// validateSpec returns the problems of a spec which can't be reconciled
+func validateSpec(spec *v1.MyResourceSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	switch {
+	case spec.Image != "":
+		errs = append(errs, validateImage(spec.Image, fldPath.Child("image"))...)
+	case spec.Message != "":
+		errs = append(errs, validateImage(spec.Message, fldPath.Child("message"))...)
+	default:
+		errs = append(errs, field.Required(fldPath.Child("image"), ""))
+	}
+	if spec.Replicas != nil && *spec.Replicas < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("replicas"), *spec.Replicas, "must not be negative"))
+	}
+	errs = append(errs, validatePort(spec.ContainerPort, fldPath.Child("containerPort"))...)
+	managed := map[string]bool{}
+	for _, envVar := range getHttpEnvVariable(&v1.HTTPSpec{}) {
+		managed[envVar.Name] = true
+	}
+	for i, envVar := range spec.Env {
+		if managed[envVar.Name] {
+			errs = append(errs, field.Forbidden(fldPath.Child("env").Index(i).Child("name"),
+				fmt.Sprintf("%s is managed by the controller, use spec.http.methods", envVar.Name)))
+		}
+	}
+	if spec.HTTP != nil {
+		supported := make([]string, len(v1.HTTPMethods))
+		for i, method := range v1.HTTPMethods {
+			supported[i] = string(method)
+		}
+		for i, method := range spec.HTTP.Methods {
+			if !method.IsSupported() {
+				errs = append(errs, field.NotSupported(fldPath.Child("http", "methods").Index(i), method, supported))
+			}
+		}
+	}
+	if spec.Service != nil {
+		servicePath := fldPath.Child("service")
+		switch spec.Service.Type {
+		case "", apiv1.ServiceTypeClusterIP, apiv1.ServiceTypeNodePort, apiv1.ServiceTypeLoadBalancer:
+		default:
+			errs = append(errs, field.NotSupported(servicePath.Child("type"), spec.Service.Type, []string{
+				string(apiv1.ServiceTypeClusterIP), string(apiv1.ServiceTypeNodePort), string(apiv1.ServiceTypeLoadBalancer)}))
+		}
+		errs = append(errs, validatePort(spec.Service.Port, servicePath.Child("port"))...)
+		if nodePortErrs := validatePort(spec.Service.NodePort, servicePath.Child("nodePort")); len(nodePortErrs) > 0 {
+			errs = append(errs, nodePortErrs...)
+		} else if spec.Service.NodePort != 0 && (spec.Service.Type == "" || spec.Service.Type == apiv1.ServiceTypeClusterIP) {
+			errs = append(errs, field.Forbidden(servicePath.Child("nodePort"),
+				"only allowed with the NodePort and LoadBalancer types"))
+		}
+	}
+	if spec.Ingress != nil {
+		ingressPath := fldPath.Child("ingress")
+		if spec.Ingress.Host == "" {
+			errs = append(errs, field.Required(ingressPath.Child("host"), ""))
+		} else {
+			for _, msg := range validation.IsDNS1123Subdomain(spec.Ingress.Host) {
+				errs = append(errs, field.Invalid(ingressPath.Child("host"), spec.Ingress.Host, msg))
+			}
+		}
+		if spec.Ingress.Path != "" && !strings.HasPrefix(spec.Ingress.Path, "/") {
+			errs = append(errs, field.Invalid(ingressPath.Child("path"), spec.Ingress.Path, "must start with /"))
+		}
+	}
+	return errs
+}
+
+// ValidateMyResource returns the problems of a MyResource which keep the
+// controller from reconciling it, with the path of the offending field
+func ValidateMyResource(resource *v1.MyResource) field.ErrorList {
+	errs := validateName(resource.Name, field.NewPath("metadata", "name"))
+	return append(errs, validateSpec(&resource.Spec, field.NewPath("spec"))...)
+}
+
+// ValidateMyResourceUpdate returns the problems of an update of a
+// MyResource. The deletion policy can't change once the finalizer applies
+// it, the deprecated fields may still change, the defaults translate them
+func ValidateMyResourceUpdate(resource, old *v1.MyResource) field.ErrorList {
+	var errs field.ErrorList
+	if old.DeletionTimestamp != nil && resource.Spec.DeletionPolicy != old.Spec.DeletionPolicy {
+		errs = append(errs, field.Invalid(field.NewPath("spec", "deletionPolicy"), resource.Spec.DeletionPolicy,
+			"is immutable while the MyResource is being deleted"))
+	}
+	// an unchanged spec was accepted before, it must not block updates of
+	// the metadata like the removal of the finalizer
+	if !equality.Semantic.DeepEqual(resource.Spec, old.Spec) {
+		errs = append(errs, ValidateMyResource(resource)...)
+	}
+	return errs
+}
+
+// checkSpec warns about the deprecated fields of a resource and returns false
+// when its spec is invalid. Retrying an invalid spec is pointless, so it is
+// only recorded in an event and the status until the spec changes
+func (s *HttpService) checkSpec(resource *v1.MyResource) bool {
+	// only warn once per change of the spec instead of on every resync
+	if resource.Spec.SomeValue != nil && resource.Generation != resource.Status.ObservedGeneration {
+		if resource.Spec.HTTP != nil {
+			s.Recorder.Event(resource, apiv1.EventTypeWarning, ReasonDeprecated,
+				"spec.someValue is deprecated and ignored since spec.http is set, remove it")
+		} else {
+			s.Recorder.Eventf(resource, apiv1.EventTypeWarning, ReasonDeprecated,
+				"spec.someValue is deprecated, replace it with spec.http.methods: %v",
+				resource.Spec.EffectiveHTTP().Methods)
+		}
+	}
+
+	errs := ValidateMyResource(resource)
+	if len(errs) == 0 {
+		return true
+	}
+	err := fmt.Errorf("invalid spec: %v", errs.ToAggregate())
+	log.Errorf("Myresource (%s/%s) has an %v", resource.Namespace, resource.Name, err)
+	s.Recorder.Event(resource, apiv1.EventTypeWarning, ReasonInvalidSpec, err.Error())
+	s.writeStatus(resource, s.cachedDeployment(resource), err)
+	return false
+}
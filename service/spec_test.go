@@ -0,0 +1,250 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	myresourcefake "k8s-controller-custom-resource/pkg/client/clientset/versioned/fake"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func envValues(env []apiv1.EnvVar) map[string]string {
+	values := map[string]string{}
+	for _, envVar := range env {
+		values[envVar.Name] = envVar.Value
+	}
+	return values
+}
+
+func TestGetHttpEnvVariable(t *testing.T) {
+	env := getHttpEnvVariable(&v1.HTTPSpec{Methods: []v1.HTTPMethod{v1.HTTPMethodPost, v1.HTTPMethodDelete}})
+	assert.Equal(t, []apiv1.EnvVar{
+		{Name: "ENABLE_GET", Value: "false"},
+		{Name: "ENABLE_PUT", Value: "false"},
+		{Name: "ENABLE_POST", Value: "true"},
+		{Name: "ENABLE_DELETE", Value: "true"},
+		{Name: "ENABLE_PATCH", Value: "false"},
+	}, env)
+}
+
+func TestSomeValueIsTranslated(t *testing.T) {
+	for someValue, enabled := range map[int32][]string{
+		1: {"ENABLE_GET"},
+		2: {"ENABLE_PUT"},
+		3: {"ENABLE_GET", "ENABLE_PUT"},
+		4: {},
+		7: {"ENABLE_GET"},
+	} {
+		spec := v1.MyResourceSpec{SomeValue: &someValue}
+		values := envValues(getHttpEnvVariable(spec.EffectiveHTTP()))
+		for _, name := range enabled {
+			assert.Equal(t, "true", values[name], "someValue %d", someValue)
+			delete(values, name)
+		}
+		for name, value := range values {
+			assert.Equal(t, "false", value, "someValue %d enables %s", someValue, name)
+		}
+	}
+
+	// spec.http wins over someValue, GET is the default without both
+	someValue := int32(2)
+	spec := v1.MyResourceSpec{SomeValue: &someValue, HTTP: &v1.HTTPSpec{Methods: []v1.HTTPMethod{v1.HTTPMethodPatch}}}
+	assert.Equal(t, []v1.HTTPMethod{v1.HTTPMethodPatch}, spec.EffectiveHTTP().Methods)
+	assert.Equal(t, []v1.HTTPMethod{v1.HTTPMethodGet}, (&v1.MyResourceSpec{}).EffectiveHTTP().Methods)
+}
+
+func TestReconcileHttpWarnsAboutSomeValue(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	resource.Generation = 1
+	client := fake.NewSimpleClientset()
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+
+	assert.Nil(t, s.ReconcileHttp(resource))
+	assert.Equal(t, []string{
+		"Warning DeprecatedField spec.someValue is deprecated, replace it with spec.http.methods: [GET]",
+		"Normal Created Created Service demo",
+		"Normal Created Created Deployment demo",
+	}, recordedEvents(s))
+
+	// the warning is not repeated until the spec changes
+	resource, err := s.myResources("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, s.ReconcileHttp(resource))
+	assert.Empty(t, recordedEvents(s))
+}
+
+func TestReconcileHttpRejectsUnsupportedMethod(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	resource.Spec.SomeValue = nil
+	resource.Spec.HTTP = &v1.HTTPSpec{Methods: []v1.HTTPMethod{v1.HTTPMethodGet, "TRACE"}}
+	client := fake.NewSimpleClientset()
+	s := newTestService(client, myresourcefake.NewSimpleClientset(resource))
+
+	// an invalid spec is not retried, it waits for the next change
+	assert.Nil(t, s.ReconcileHttp(resource))
+	for _, action := range client.Actions() {
+		// the status looks up the Service through the lister reading the client
+		assert.Equal(t, "get", action.GetVerb())
+	}
+	events := recordedEvents(s)
+	if assert.Len(t, events, 1) {
+		assert.Contains(t, events[0], `Warning InvalidSpec invalid spec: spec.http.methods[1]: Unsupported value: "TRACE"`)
+	}
+	latest, err := s.myResources("team-a").Get("demo", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Contains(t, latest.Status.LastError, "Unsupported value")
+}
+
+func TestCreateHttpServiceSpecUsesSpecFields(t *testing.T) {
+	myResource := newNamespacedResource("team-a", "uid-a")
+	myResource.Spec.Image = "registry.example.com/web:1.2"
+	myResource.Spec.Replicas = int32Ptr(3)
+	myResource.Spec.ContainerPort = 9090
+	myResource.Spec.Resources = apiv1.ResourceRequirements{
+		Requests: apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("100m")},
+		Limits:   apiv1.ResourceList{apiv1.ResourceMemory: resource.MustParse("128Mi")},
+	}
+	myResource.Spec.Env = []apiv1.EnvVar{{Name: "GREETING", Value: "hello"}}
+	myResource.Spec.EnvFrom = []apiv1.EnvFromSource{
+		{ConfigMapRef: &apiv1.ConfigMapEnvSource{LocalObjectReference: apiv1.LocalObjectReference{Name: "web"}}},
+	}
+
+	deployment := createHttpServiceSpec(myResource)
+	assert.Equal(t, int32(3), *deployment.Spec.Replicas)
+	container := deployment.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "registry.example.com/web:1.2", container.Image)
+	assert.Equal(t, apiv1.PullIfNotPresent, container.ImagePullPolicy)
+	assert.Equal(t, int32(9090), container.Ports[0].ContainerPort)
+	assert.Equal(t, myResource.Spec.Resources, container.Resources)
+	assert.Equal(t, myResource.Spec.EnvFrom, container.EnvFrom)
+	// the managed env vars come first, then the ones of the spec
+	assert.Equal(t, append(getHttpEnvVariable(v1.HTTPSpecFromSomeValue(1)), myResource.Spec.Env...), container.Env)
+
+	// the spec of the resource itself is not defaulted
+	myResource.Spec.Replicas = nil
+	createHttpServiceSpec(myResource)
+	assert.Nil(t, myResource.Spec.Replicas)
+}
+
+func TestCreateHttpServiceSpecDefaults(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	deployment := createHttpServiceSpec(resource)
+
+	assert.Equal(t, int32(1), *deployment.Spec.Replicas)
+	container := deployment.Spec.Template.Spec.Containers[0]
+	// older resources name their image in message
+	assert.Equal(t, "nginx", container.Image)
+	assert.Equal(t, apiv1.PullAlways, container.ImagePullPolicy)
+	assert.Equal(t, int32(v1.DefaultContainerPort), container.Ports[0].ContainerPort)
+	assert.Empty(t, container.EnvFrom)
+}
+
+func TestValidateSpec(t *testing.T) {
+	for name, test := range map[string]struct {
+		mutate  func(spec *v1.MyResourceSpec)
+		problem string
+	}{
+		"no image":          {func(spec *v1.MyResourceSpec) { spec.Message = "" }, "spec.image: Required value"},
+		"negative replicas": {func(spec *v1.MyResourceSpec) { spec.Replicas = int32Ptr(-1) }, "spec.replicas"},
+		"port out of range": {func(spec *v1.MyResourceSpec) { spec.ContainerPort = 70000 }, "spec.containerPort"},
+		"negative port":     {func(spec *v1.MyResourceSpec) { spec.ContainerPort = -1 }, "or 0 to leave it unset"},
+		"managed env var": {func(spec *v1.MyResourceSpec) {
+			spec.Env = []apiv1.EnvVar{{Name: "ENABLE_PUT", Value: "true"}}
+		}, "spec.env[0].name: Forbidden: ENABLE_PUT is managed by the controller"},
+		"unknown service type": {func(spec *v1.MyResourceSpec) {
+			spec.Service = &v1.ServiceSpec{Type: apiv1.ServiceTypeExternalName}
+		}, "spec.service.type"},
+		"node port of a cluster IP": {func(spec *v1.MyResourceSpec) {
+			spec.Service = &v1.ServiceSpec{NodePort: 30080}
+		}, "spec.service.nodePort: Forbidden: only allowed"},
+		"ingress without host": {func(spec *v1.MyResourceSpec) {
+			spec.Ingress = &v1.IngressSpec{Path: "/demo"}
+		}, "spec.ingress.host: Required value"},
+		"relative ingress path": {func(spec *v1.MyResourceSpec) {
+			spec.Ingress = &v1.IngressSpec{Host: "demo.example.com", Path: "demo"}
+		}, "spec.ingress.path"},
+		"image with an empty tag": {func(spec *v1.MyResourceSpec) { spec.Image = "nginx:" },
+			"spec.image: Invalid value: \"nginx:\""},
+		"upper case image": {func(spec *v1.MyResourceSpec) { spec.Message = "Nginx" }, "spec.message: Invalid value"},
+		"short digest":     {func(spec *v1.MyResourceSpec) { spec.Image = "nginx@sha256:abc" }, "spec.image"},
+	} {
+		spec := newNamespacedResource("team-a", "uid-a").Spec
+		assert.Empty(t, validateSpec(&spec, field.NewPath("spec")), name)
+		test.mutate(&spec)
+		errs := validateSpec(&spec, field.NewPath("spec"))
+		if assert.Len(t, errs, 1, name) {
+			assert.Contains(t, errs[0].Error(), test.problem, name)
+		}
+	}
+
+	for _, image := range []string{
+		"nginx",
+		"library/nginx:1.15",
+		"localhost:5000/team/web:v2.0-rc.1",
+		"registry.example.com/web@sha256:0123456789abcdef0123456789abcdef",
+	} {
+		assert.Empty(t, validateImage(image, field.NewPath("spec", "image")), image)
+	}
+}
+
+func TestValidateMyResource(t *testing.T) {
+	resource := newNamespacedResource("team-a", "uid-a")
+	assert.Empty(t, ValidateMyResource(resource))
+
+	// the Service needs a DNS-1035 label and the instance label 63 characters
+	resource.Name = strings.Repeat("a", 64)
+	errs := ValidateMyResource(resource)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "metadata.name", errs[0].Field)
+		assert.Contains(t, errs[0].Detail, "63")
+	}
+	resource.Name = "1st"
+	assert.Len(t, ValidateMyResource(resource), 1)
+}
+
+func TestValidateMyResourceUpdate(t *testing.T) {
+	old := newNamespacedResource("team-a", "uid-a")
+	old.Spec.HTTP = nil
+	assert.Empty(t, ValidateMyResourceUpdate(old.DeepCopy(), old))
+
+	// the deprecated fields can be removed or changed, kubectl apply of an
+	// old manifest keeps working
+	resource := old.DeepCopy()
+	resource.Spec.Message = ""
+	resource.Spec.SomeValue = nil
+	resource.Spec.Image = "nginx"
+	assert.Empty(t, ValidateMyResourceUpdate(resource, old))
+	resource = old.DeepCopy()
+	resource.Spec.Message = "httpd"
+	resource.Spec.SomeValue = int32Ptr(2)
+	assert.Empty(t, ValidateMyResourceUpdate(resource, old))
+	resource.Spec.Message = "Httpd"
+	errs := ValidateMyResourceUpdate(resource, old)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.message", errs[0].Field)
+	}
+
+	// the finalizer applies the deletion policy of the deleted resource
+	now := metav1.Now()
+	old.DeletionTimestamp = &now
+	resource = old.DeepCopy()
+	resource.Spec.DeletionPolicy = v1.DeletionPolicyOrphan
+	errs = ValidateMyResourceUpdate(resource, old)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.deletionPolicy", errs[0].Field)
+	}
+
+	// an invalid spec which didn't change doesn't block the removal of the finalizer
+	old.Spec.Replicas = int32Ptr(-1)
+	resource = old.DeepCopy()
+	resource.Finalizers = nil
+	assert.Empty(t, ValidateMyResourceUpdate(resource, old))
+	resource.Spec.Replicas = int32Ptr(-2)
+	assert.Len(t, ValidateMyResourceUpdate(resource, old), 1)
+}
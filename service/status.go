@@ -0,0 +1,131 @@
+package service
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// setCondition adds or replaces the condition of the given type, keeping the
+// previous transition time when the status did not change
+func setCondition(status *v1.MyResourceStatus, condType v1.MyResourceConditionType,
+	condStatus apiv1.ConditionStatus, reason, message string) {
+	condition := v1.MyResourceCondition{
+		Type:               condType,
+		Status:             condStatus,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+	for i := range status.Conditions {
+		if status.Conditions[i].Type != condType {
+			continue
+		}
+		if status.Conditions[i].Status == condStatus {
+			condition.LastTransitionTime = status.Conditions[i].LastTransitionTime
+		}
+		status.Conditions[i] = condition
+		return
+	}
+	status.Conditions = append(status.Conditions, condition)
+}
+
+// getDeploymentCondition returns the deployment condition of the given type, or nil
+func getDeploymentCondition(deployment *appsv1.Deployment, condType appsv1.DeploymentConditionType) *appsv1.DeploymentCondition {
+	for i := range deployment.Status.Conditions {
+		if deployment.Status.Conditions[i].Type == condType {
+			return &deployment.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// computeStatus builds the status of the resource from the observed Deployment
+// and the result of the reconcile, deployment may be nil if it does not exist
+func computeStatus(resource *v1.MyResource, deployment *appsv1.Deployment, reconcileErr error) v1.MyResourceStatus {
+	status := *resource.Status.DeepCopy()
+	status.ObservedGeneration = resource.Generation
+
+	if reconcileErr != nil {
+		status.LastError = reconcileErr.Error()
+		setCondition(&status, v1.MyResourceDegraded, apiv1.ConditionTrue, "ReconcileFailed", reconcileErr.Error())
+	} else {
+		status.LastError = ""
+		setCondition(&status, v1.MyResourceDegraded, apiv1.ConditionFalse, "ReconcileSucceeded", "")
+	}
+
+	if deployment == nil {
+		status.AvailableReplicas = 0
+		setCondition(&status, v1.MyResourceReady, apiv1.ConditionFalse, "DeploymentNotFound",
+			fmt.Sprintf("Deployment %s does not exist", resource.Name))
+		setCondition(&status, v1.MyResourceProgressing, apiv1.ConditionFalse, "DeploymentNotFound", "")
+		return status
+	}
+
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	available := deployment.Status.AvailableReplicas
+	status.AvailableReplicas = available
+	message := fmt.Sprintf("%d/%d replicas available", available, desired)
+
+	ready := available >= desired &&
+		deployment.Status.UpdatedReplicas >= desired &&
+		deployment.Status.ObservedGeneration >= deployment.Generation
+	if ready {
+		setCondition(&status, v1.MyResourceReady, apiv1.ConditionTrue, "MinimumReplicasAvailable", message)
+		setCondition(&status, v1.MyResourceProgressing, apiv1.ConditionFalse, "RolloutComplete", message)
+		return status
+	}
+
+	setCondition(&status, v1.MyResourceReady, apiv1.ConditionFalse, "ReplicasUnavailable", message)
+	if progressing := getDeploymentCondition(deployment, appsv1.DeploymentProgressing); progressing != nil &&
+		progressing.Status == apiv1.ConditionFalse {
+		setCondition(&status, v1.MyResourceProgressing, apiv1.ConditionFalse, progressing.Reason, progressing.Message)
+	} else {
+		setCondition(&status, v1.MyResourceProgressing, apiv1.ConditionTrue, "RollingOut", message)
+	}
+	return status
+}
+
+// UpdateStatus writes the status computed from the observed Deployment
+// through the status subresource of the resource
+func (s *HttpService) UpdateStatus(resource *v1.MyResource, deployment *appsv1.Deployment, reconcileErr error) error {
+	myResourceClient := s.myResources(resource.Namespace)
+	latest := resource.DeepCopy()
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Retrieve the latest version only after a conflict, the given
+		// resource is usually up to date
+		if latest == nil {
+			var getErr error
+			latest, getErr = myResourceClient.Get(resource.Name, metav1.GetOptions{})
+			if getErr != nil {
+				return getErr
+			}
+		}
+		status := computeStatus(latest, deployment, reconcileErr)
+		status.ServiceDNSName = s.serviceDNSName(latest)
+		status.URL = s.ingressURL(latest)
+		if equality.Semantic.DeepEqual(status, latest.Status) {
+			return nil
+		}
+		latest.Status = status
+		log.Infof("Updating status of myresource (%s/%s)", latest.Namespace, latest.Name)
+		_, updateErr := myResourceClient.UpdateStatus(latest)
+		if updateErr != nil {
+			latest = nil
+		}
+		return updateErr
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update status of myresource %s: \n%v", resource.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func findCondition(status v1.MyResourceStatus, condType v1.MyResourceConditionType) *v1.MyResourceCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == condType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func TestComputeStatusReady(t *testing.T) {
+	resource := &v1.MyResource{ObjectMeta: metav1.ObjectMeta{Name: "demo", Generation: 3}}
+	deployment := &appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{Replicas: int32Ptr(1)},
+		Status: appsv1.DeploymentStatus{
+			AvailableReplicas: 1,
+			UpdatedReplicas:   1,
+		},
+	}
+
+	status := computeStatus(resource, deployment, nil)
+	assert.Equal(t, int64(3), status.ObservedGeneration)
+	assert.Equal(t, int32(1), status.AvailableReplicas)
+	assert.Empty(t, status.LastError)
+	assert.Equal(t, apiv1.ConditionTrue, findCondition(status, v1.MyResourceReady).Status)
+	assert.Equal(t, apiv1.ConditionFalse, findCondition(status, v1.MyResourceProgressing).Status)
+	assert.Equal(t, apiv1.ConditionFalse, findCondition(status, v1.MyResourceDegraded).Status)
+}
+
+func TestComputeStatusProgressing(t *testing.T) {
+	resource := &v1.MyResource{ObjectMeta: metav1.ObjectMeta{Name: "demo"}}
+	deployment := &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Replicas: int32Ptr(2)}}
+
+	status := computeStatus(resource, deployment, nil)
+	assert.Equal(t, apiv1.ConditionFalse, findCondition(status, v1.MyResourceReady).Status)
+	assert.Equal(t, apiv1.ConditionTrue, findCondition(status, v1.MyResourceProgressing).Status)
+}
+
+func TestComputeStatusDegraded(t *testing.T) {
+	resource := &v1.MyResource{ObjectMeta: metav1.ObjectMeta{Name: "demo"}}
+
+	status := computeStatus(resource, nil, errors.New("forbidden"))
+	assert.Equal(t, "forbidden", status.LastError)
+	assert.Equal(t, apiv1.ConditionTrue, findCondition(status, v1.MyResourceDegraded).Status)
+	assert.Equal(t, "DeploymentNotFound", findCondition(status, v1.MyResourceReady).Reason)
+
+	// a later successful reconcile clears the error
+	resource.Status = status
+	status = computeStatus(resource, nil, nil)
+	assert.Empty(t, status.LastError)
+	assert.Equal(t, apiv1.ConditionFalse, findCondition(status, v1.MyResourceDegraded).Status)
+	assert.Len(t, status.Conditions, 3)
+}
+
+func TestSetConditionKeepsTransitionTime(t *testing.T) {
+	before := metav1.NewTime(metav1.Now().Add(-time.Hour))
+	status := v1.MyResourceStatus{Conditions: []v1.MyResourceCondition{
+		{Type: v1.MyResourceReady, Status: apiv1.ConditionTrue, LastTransitionTime: before},
+	}}
+
+	setCondition(&status, v1.MyResourceReady, apiv1.ConditionTrue, "MinimumReplicasAvailable", "")
+	assert.Equal(t, before, status.Conditions[0].LastTransitionTime)
+
+	setCondition(&status, v1.MyResourceReady, apiv1.ConditionFalse, "ReplicasUnavailable", "")
+	assert.NotEqual(t, before, status.Conditions[0].LastTransitionTime)
+}
@@ -5,27 +5,80 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 
-	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
-	k8sAppType "k8s.io/client-go/kubernetes/typed/apps/v1"
 	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 
 	myresourceclientset "k8s-controller-custom-resource/pkg/client/clientset/versioned"
 )
 
+// ConfigOptions selects the cluster to connect to and tunes the clients
+type ConfigOptions struct {
+	// Kubeconfig is the path of an explicit kubeconfig file
+	Kubeconfig string
+	// Context is the kubeconfig context to use instead of the current one
+	Context string
+	// QPS, Burst and UserAgent override the client defaults when set
+	QPS       float32
+	Burst     int
+	UserAgent string
+}
+
+// inClusterConfig is replaced in tests, the in-cluster config is read from
+// fixed paths of the service account
+var inClusterConfig = restclient.InClusterConfig
+
 func GetKubernetesConfig() (*restclient.Config, error) {
-	// construct the path to resolve to `~/.kube/config`
-	kubeConfigPath := os.Getenv("HOME") + "/.kube/config"
+	return GetKubernetesConfigWithOptions(ConfigOptions{})
+}
 
-	// create the config from the path
-	config, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
+// GetKubernetesConfigWithOptions loads the config from the first source
+// available, in order: the explicit kubeconfig, the files listed in the
+// KUBECONFIG env var (merged), the in-cluster service account and finally
+// `~/.kube/config`. A context can only be selected in a kubeconfig
+func GetKubernetesConfigWithOptions(options ConfigOptions) (*restclient.Config, error) {
+	config, err := loadKubernetesConfig(options)
 	if err != nil {
-		err = errors.New(fmt.Sprintf("GetKubernetesConfig:\n%v", err))
+		return nil, errors.New(fmt.Sprintf("GetKubernetesConfig:\n%v", err))
+	}
+
+	if options.QPS > 0 {
+		config.QPS = options.QPS
+	}
+	if options.Burst > 0 {
+		config.Burst = options.Burst
+	}
+	if options.UserAgent != "" {
+		config.UserAgent = options.UserAgent
+	}
+	return config, nil
+}
+
+func loadKubernetesConfig(options ConfigOptions) (*restclient.Config, error) {
+	rules := &clientcmd.ClientConfigLoadingRules{}
+	if options.Kubeconfig != "" {
+		rules.ExplicitPath = options.Kubeconfig
+	} else if env := os.Getenv(clientcmd.RecommendedConfigPathEnvVar); env != "" {
+		// later files only add what the earlier ones do not set
+		rules.Precedence = filepath.SplitList(env)
+	} else {
+		if options.Context == "" {
+			config, err := inClusterConfig()
+			if err == nil {
+				return config, nil
+			}
+			if err != restclient.ErrNotInCluster {
+				return nil, err
+			}
+		}
+		// construct the path to resolve to `~/.kube/config`
+		rules.ExplicitPath = filepath.Join(os.Getenv("HOME"), clientcmd.RecommendedHomeDir, clientcmd.RecommendedFileName)
 	}
 
-	return config, err
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: options.Context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
 }
 
 func GetKubernetesClient() (kubernetes.Interface, error) {
@@ -57,25 +110,20 @@ func GetMyKubernetesClient() (myresourceclientset.Interface, error) {
 	return myResourceClient, err
 }
 
-// retrieve the Kubernetes cluster client from outside of the cluster
-func GetBothKubernetesClient() (kubernetes.Interface, myresourceclientset.Interface) {
-	client, err := GetKubernetesClient()
+// retrieve the Kubernetes cluster clients, both clientsets share one config
+// so that it is only loaded once
+func GetBothKubernetesClient(options ConfigOptions) (kubernetes.Interface, myresourceclientset.Interface) {
+	config, err := GetKubernetesConfigWithOptions(options)
 	if err != nil {
 		log.Fatal(err)
 	}
-	myResourceClient, err := GetMyKubernetesClient()
+	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatal(fmt.Sprintf("GetKubernetesClient:\n%v", err))
 	}
-	return client, myResourceClient
-}
-
-func GetDeploymentClient() (k8sAppType.DeploymentInterface) {
-	client, err := GetKubernetesClient()
+	myResourceClient, err := myresourceclientset.NewForConfig(config)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatal(fmt.Sprintf("GetMyKubernetesClient:\n%v", err))
 	}
-	deploymentsClient := client.AppsV1().Deployments(apiv1.NamespaceDefault)
-	return deploymentsClient
+	return client, myResourceClient
 }
-
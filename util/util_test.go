@@ -1,39 +1,168 @@
 package util
 
 import (
-	"github.com/stretchr/testify/assert"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
-func TestGetKubernetesConfig(t *testing.T) {
-	_, err := os.Stat(os.Getenv("HOME") + "/.kube/config")
-	_, configErr := GetKubernetesConfig()
-	if err == nil {
-		assert.Nil(t, configErr)
-	} else if os.IsNotExist(err) {
-		assert.NotNil(t, configErr)
+// writeKubeconfig writes a kubeconfig with one cluster per context, named
+// after the context, and the first context as the current one
+func writeKubeconfig(t *testing.T, path string, contexts ...string) {
+	var clusters, users, namedContexts, current string
+	for _, name := range contexts {
+		clusters += fmt.Sprintf("- name: %s\n  cluster:\n    server: https://%s.example.com\n", name, name)
+		users += fmt.Sprintf("- name: %s\n  user:\n    token: %s-token\n", name, name)
+		namedContexts += fmt.Sprintf("- name: %s\n  context:\n    cluster: %s\n    user: %s\n", name, name, name)
+	}
+	if len(contexts) > 0 {
+		current = contexts[0]
+	}
+	content := fmt.Sprintf("apiVersion: v1\nkind: Config\nclusters:\n%susers:\n%scontexts:\n%scurrent-context: %q\n",
+		clusters, users, namedContexts, current)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
 	}
 }
 
-func TestGetKubernetesClient(t *testing.T) {
-	_, err := os.Stat(os.Getenv("HOME") + "/.kube/config")
-	_, configErr := GetKubernetesClient()
+// isolate points HOME to an empty directory, clears KUBECONFIG and makes the
+// process look like it runs outside of a cluster, it returns HOME
+func isolate(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(clientcmd.RecommendedConfigPathEnvVar, "")
+	stubInClusterConfig(t, nil, restclient.ErrNotInCluster)
+	return home
+}
+
+func stubInClusterConfig(t *testing.T, config *restclient.Config, err error) {
+	original := inClusterConfig
+	inClusterConfig = func() (*restclient.Config, error) { return config, err }
+	t.Cleanup(func() { inClusterConfig = original })
+}
 
-	if err == nil {
-		assert.Nil(t, configErr)
-	} else if os.IsNotExist(err) {
-		assert.NotNil(t, configErr)
+func writeHomeKubeconfig(t *testing.T, home string, contexts ...string) {
+	dir := filepath.Join(home, clientcmd.RecommendedHomeDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
 	}
+	writeKubeconfig(t, filepath.Join(dir, clientcmd.RecommendedFileName), contexts...)
+}
+
+func TestGetKubernetesConfigExplicitPath(t *testing.T) {
+	home := isolate(t)
+	writeHomeKubeconfig(t, home, "home")
+	explicit := filepath.Join(t.TempDir(), "explicit")
+	writeKubeconfig(t, explicit, "explicit")
+	env := filepath.Join(t.TempDir(), "env")
+	writeKubeconfig(t, env, "env")
+	t.Setenv(clientcmd.RecommendedConfigPathEnvVar, env)
+
+	config, err := GetKubernetesConfigWithOptions(ConfigOptions{Kubeconfig: explicit})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://explicit.example.com", config.Host)
+
+	_, err = GetKubernetesConfigWithOptions(ConfigOptions{Kubeconfig: filepath.Join(home, "missing")})
+	assert.NotNil(t, err)
+}
+
+func TestGetKubernetesConfigMergesKubeconfigEnv(t *testing.T) {
+	home := isolate(t)
+	writeHomeKubeconfig(t, home, "home")
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first")
+	writeKubeconfig(t, first, "first")
+	second := filepath.Join(dir, "second")
+	writeKubeconfig(t, second, "second", "first")
+	t.Setenv(clientcmd.RecommendedConfigPathEnvVar, strings.Join([]string{first, second}, string(os.PathListSeparator)))
+
+	// the current context of the first file wins
+	config, err := GetKubernetesConfigWithOptions(ConfigOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://first.example.com", config.Host)
+
+	// contexts of the later files are merged in
+	config, err = GetKubernetesConfigWithOptions(ConfigOptions{Context: "second"})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://second.example.com", config.Host)
+	assert.Equal(t, "second-token", config.BearerToken)
+
+	_, err = GetKubernetesConfigWithOptions(ConfigOptions{Context: "unknown"})
+	assert.NotNil(t, err)
+}
+
+func TestGetKubernetesConfigInCluster(t *testing.T) {
+	home := isolate(t)
+	writeHomeKubeconfig(t, home, "home")
+	stubInClusterConfig(t, &restclient.Config{Host: "https://10.0.0.1:443"}, nil)
+
+	config, err := GetKubernetesConfigWithOptions(ConfigOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://10.0.0.1:443", config.Host)
+
+	// a context only exists in a kubeconfig
+	config, err = GetKubernetesConfigWithOptions(ConfigOptions{Context: "home"})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://home.example.com", config.Host)
+
+	stubInClusterConfig(t, nil, errors.New("invalid service account token"))
+	_, err = GetKubernetesConfigWithOptions(ConfigOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestGetKubernetesConfigHomeFallback(t *testing.T) {
+	home := isolate(t)
+
+	_, err := GetKubernetesConfig()
+	assert.NotNil(t, err)
+
+	writeHomeKubeconfig(t, home, "home")
+	config, err := GetKubernetesConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "https://home.example.com", config.Host)
+}
+
+func TestGetKubernetesConfigOverrides(t *testing.T) {
+	home := isolate(t)
+	writeHomeKubeconfig(t, home, "home")
+
+	config, err := GetKubernetesConfigWithOptions(ConfigOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, float32(0), config.QPS)
+	assert.Equal(t, 0, config.Burst)
+	assert.Equal(t, "", config.UserAgent)
+
+	config, err = GetKubernetesConfigWithOptions(ConfigOptions{QPS: 50, Burst: 100, UserAgent: "myresource-controller"})
+	assert.Nil(t, err)
+	assert.Equal(t, float32(50), config.QPS)
+	assert.Equal(t, 100, config.Burst)
+	assert.Equal(t, "myresource-controller", config.UserAgent)
+}
+
+func TestGetKubernetesClient(t *testing.T) {
+	home := isolate(t)
+	_, err := GetKubernetesClient()
+	assert.NotNil(t, err)
+
+	writeHomeKubeconfig(t, home, "home")
+	_, err = GetKubernetesClient()
+	assert.Nil(t, err)
 }
 
 func TestGetMyKubernetesClient(t *testing.T) {
-	_, err := os.Stat(os.Getenv("HOME") + "/.kube/config")
-	_, configErr := GetMyKubernetesClient()
+	home := isolate(t)
+	_, err := GetMyKubernetesClient()
+	assert.NotNil(t, err)
 
-	if err == nil {
-		assert.Nil(t, configErr)
-	} else if os.IsNotExist(err) {
-		assert.NotNil(t, configErr)
-	}
+	writeHomeKubeconfig(t, home, "home")
+	_, err = GetMyKubernetesClient()
+	assert.Nil(t, err)
 }
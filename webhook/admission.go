@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admission_v1beta1 "k8s.io/api/admission/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	myresource_v1 "k8s-controller-custom-resource/pkg/apis/myresource/v1"
+)
+
+// myResourceKind is the only kind the admission webhooks accept, the API
+// server converts the other versions to it
+var myResourceKind = meta_v1.GroupVersionKind{
+	Group:   myresource_v1.SchemeGroupVersion.Group,
+	Version: myresource_v1.SchemeGroupVersion.Version,
+	Kind:    "MyResource",
+}
+
+// admitFunc decides about an admission request of a MyResource
+type admitFunc func(request *admission_v1beta1.AdmissionRequest) *admission_v1beta1.AdmissionResponse
+
+// newAdmissionHandler returns the handler of an admission webhook. The
+// AdmissionReview of admission.k8s.io/v1 has the same fields as the one of
+// v1beta1, so the response is sent in the version of the request
+func newAdmissionHandler(admit admitFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var review admission_v1beta1.AdmissionReview
+		if !readReview(w, r, &review) {
+			return
+		}
+		if review.Request == nil {
+			http.Error(w, "the AdmissionReview has no request", http.StatusBadRequest)
+			return
+		}
+
+		var response *admission_v1beta1.AdmissionResponse
+		if review.Request.Kind != myResourceKind {
+			response = deny(http.StatusBadRequest, fmt.Sprintf("expected a %s, got a %s",
+				myResourceKind, review.Request.Kind))
+		} else {
+			response = admit(review.Request)
+		}
+		response.UID = review.Request.UID
+		writeReview(w, &admission_v1beta1.AdmissionReview{TypeMeta: review.TypeMeta, Response: response})
+	})
+}
+
+// decodeMyResource returns the MyResource of an admission request
+func decodeMyResource(raw runtime.RawExtension) (*myresource_v1.MyResource, error) {
+	resource := &myresource_v1.MyResource{}
+	if err := json.Unmarshal(raw.Raw, resource); err != nil {
+		return nil, fmt.Errorf("invalid MyResource: \n%v", err)
+	}
+	return resource, nil
+}
+
+// allow returns the response admitting the request unchanged
+func allow() *admission_v1beta1.AdmissionResponse {
+	return &admission_v1beta1.AdmissionResponse{Allowed: true}
+}
+
+// deny returns the response rejecting the request with the message
+func deny(code int32, message string) *admission_v1beta1.AdmissionResponse {
+	return &admission_v1beta1.AdmissionResponse{
+		Result: &meta_v1.Status{Status: meta_v1.StatusFailure, Code: code, Message: message},
+	}
+}
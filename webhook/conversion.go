@@ -0,0 +1,152 @@
+// Package webhook serves the webhooks the API server calls for MyResources
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	myresource_v1 "k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	myresource_v2 "k8s-controller-custom-resource/pkg/apis/myresource/v2"
+)
+
+// ConvertPath is the path of the conversion webhook, it has to match the
+// clientConfig of the CRD
+const ConvertPath = "/convert"
+
+// ConversionReview is the request and the response of a conversion webhook,
+// the same as in apiextensions.k8s.io/v1 and v1beta1
+type ConversionReview struct {
+	meta_v1.TypeMeta `json:",inline"`
+	Request          *ConversionRequest  `json:"request,omitempty"`
+	Response         *ConversionResponse `json:"response,omitempty"`
+}
+
+// ConversionRequest asks to convert the objects to the DesiredAPIVersion
+type ConversionRequest struct {
+	UID               types.UID              `json:"uid"`
+	DesiredAPIVersion string                 `json:"desiredAPIVersion"`
+	Objects           []runtime.RawExtension `json:"objects"`
+}
+
+// ConversionResponse holds the converted objects in the order of the
+// request, or the reason why they could not be converted
+type ConversionResponse struct {
+	UID              types.UID              `json:"uid"`
+	ConvertedObjects []runtime.RawExtension `json:"convertedObjects"`
+	Result           meta_v1.Status         `json:"result"`
+}
+
+// newScheme returns a scheme with every version of MyResource and the
+// conversions between them
+func newScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{myresource_v1.AddToScheme, myresource_v2.AddToScheme} {
+		if err := addToScheme(scheme); err != nil {
+			panic(err)
+		}
+	}
+	return scheme
+}
+
+// NewConversionHandler returns the handler converting MyResources between
+// the versions served by the CRD
+func NewConversionHandler() http.Handler {
+	scheme := newScheme()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var review ConversionReview
+		if !readReview(w, r, &review) {
+			return
+		}
+		if review.Request == nil {
+			http.Error(w, "the ConversionReview has no request", http.StatusBadRequest)
+			return
+		}
+
+		response := &ConversionResponse{UID: review.Request.UID}
+		converted, err := convertObjects(scheme, review.Request)
+		if err != nil {
+			log.Errorf("Error converting MyResources to %s:\n%v", review.Request.DesiredAPIVersion, err)
+			response.Result = meta_v1.Status{Status: meta_v1.StatusFailure, Message: err.Error()}
+		} else {
+			response.ConvertedObjects = converted
+			response.Result = meta_v1.Status{Status: meta_v1.StatusSuccess}
+		}
+		// answer in the version of the request
+		writeReview(w, &ConversionReview{TypeMeta: review.TypeMeta, Response: response})
+	})
+}
+
+// convertObjects converts every object of the request, it fails when one
+// of them can't be converted
+func convertObjects(scheme *runtime.Scheme, request *ConversionRequest) ([]runtime.RawExtension, error) {
+	desired, err := schema.ParseGroupVersion(request.DesiredAPIVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := make([]runtime.RawExtension, 0, len(request.Objects))
+	for i, raw := range request.Objects {
+		var typeMeta meta_v1.TypeMeta
+		if err := json.Unmarshal(raw.Raw, &typeMeta); err != nil {
+			return nil, fmt.Errorf("object %d is invalid: \n%v", i, err)
+		}
+		gvk := typeMeta.GroupVersionKind()
+		in, err := scheme.New(gvk)
+		if err != nil {
+			return nil, fmt.Errorf("object %d: \n%v", i, err)
+		}
+		if err := json.Unmarshal(raw.Raw, in); err != nil {
+			return nil, fmt.Errorf("object %d is not a valid %s: \n%v", i, gvk, err)
+		}
+
+		out, err := scheme.New(desired.WithKind(gvk.Kind))
+		if err != nil {
+			return nil, fmt.Errorf("object %d: \n%v", i, err)
+		}
+		if gvk.GroupVersion() == desired {
+			out = in
+		} else if err := scheme.Convert(in, out, nil); err != nil {
+			return nil, fmt.Errorf("error converting object %d from %s: \n%v", i, gvk, err)
+		}
+		out.GetObjectKind().SetGroupVersionKind(desired.WithKind(gvk.Kind))
+
+		data, err := json.Marshal(out)
+		if err != nil {
+			return nil, err
+		}
+		converted = append(converted, runtime.RawExtension{Raw: data})
+	}
+	return converted, nil
+}
+
+// readReview decodes the review posted to a webhook, it answers the
+// request itself and returns false when that fails
+func readReview(w http.ResponseWriter, r *http.Request, review interface{}) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(review); err != nil {
+		http.Error(w, fmt.Sprintf("invalid review: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writeReview answers a webhook request with the review
+func writeReview(w http.ResponseWriter, review interface{}) {
+	data, err := json.Marshal(review)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,124 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// post sends the review to the handler on a local server and decodes the
+// review of the response into out
+func post(t *testing.T, handler http.Handler, review string, out interface{}) int {
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "application/json", bytes.NewBufferString(review))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+const v1ToV2Review = `{
+  "apiVersion": "apiextensions.k8s.io/v1",
+  "kind": "ConversionReview",
+  "request": {
+    "uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+    "desiredAPIVersion": "trstringer.com/v2",
+    "objects": [{
+      "apiVersion": "trstringer.com/v1",
+      "kind": "MyResource",
+      "metadata": {"name": "demo", "namespace": "default", "uid": "0b3d2c1a"},
+      "spec": {"message": "k2star0118/practice-gin-gonic:v0.0.1", "someValue": 2, "containerPort": 8080,
+               "service": {"type": "NodePort", "port": 80}},
+      "status": {"availableReplicas": 1, "serviceDNSName": "demo.default.svc"}
+    }, {
+      "apiVersion": "trstringer.com/v2",
+      "kind": "MyResource",
+      "metadata": {"name": "web", "namespace": "default"},
+      "spec": {"container": {"image": "nginx"}}
+    }]
+  }
+}`
+
+func TestConversionHandler(t *testing.T) {
+	var review ConversionReview
+	assert.Equal(t, http.StatusOK, post(t, NewConversionHandler(), v1ToV2Review, &review))
+	assert.Equal(t, "apiextensions.k8s.io/v1", review.APIVersion)
+	assert.Equal(t, "ConversionReview", review.Kind)
+	if !assert.NotNil(t, review.Response) {
+		return
+	}
+	assert.Equal(t, "705ab4f5-6393-11e8-b7cc-42010a800002", string(review.Response.UID))
+	assert.Equal(t, meta_v1.StatusSuccess, review.Response.Result.Status)
+	if assert.Len(t, review.Response.ConvertedObjects, 2) {
+		assert.JSONEq(t, `{
+		  "apiVersion": "trstringer.com/v2",
+		  "kind": "MyResource",
+		  "metadata": {"name": "demo", "namespace": "default", "uid": "0b3d2c1a", "creationTimestamp": null,
+		               "annotations": {"trstringer.com/v1-deprecated-fields":
+		                 "{\"message\":\"k2star0118/practice-gin-gonic:v0.0.1\",\"someValue\":2,\"imageFromMessage\":true,\"httpFromSomeValue\":true}"}},
+		  "spec": {"container": {"image": "k2star0118/practice-gin-gonic:v0.0.1", "port": 8080, "resources": {}},
+		           "http": {"methods": ["PUT"]}, "service": {"type": "NodePort", "port": 80}},
+		  "status": {"availableReplicas": 1, "serviceDNSName": "demo.default.svc"}
+		}`, string(review.Response.ConvertedObjects[0].Raw))
+		assert.JSONEq(t, `{
+		  "apiVersion": "trstringer.com/v2",
+		  "kind": "MyResource",
+		  "metadata": {"name": "web", "namespace": "default", "creationTimestamp": null},
+		  "spec": {"container": {"image": "nginx", "resources": {}}},
+		  "status": {"availableReplicas": 0}
+		}`, string(review.Response.ConvertedObjects[1].Raw))
+	}
+
+	// and back to v1
+	var objects []json.RawMessage
+	for _, object := range review.Response.ConvertedObjects {
+		objects = append(objects, object.Raw)
+	}
+	request, _ := json.Marshal(map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1beta1",
+		"kind":       "ConversionReview",
+		"request":    map[string]interface{}{"uid": "1", "desiredAPIVersion": "trstringer.com/v1", "objects": objects},
+	})
+	review = ConversionReview{}
+	assert.Equal(t, http.StatusOK, post(t, NewConversionHandler(), string(request), &review))
+	assert.Equal(t, "apiextensions.k8s.io/v1beta1", review.APIVersion)
+	if assert.Len(t, review.Response.ConvertedObjects, 2) {
+		assert.JSONEq(t, `{
+		  "apiVersion": "trstringer.com/v1",
+		  "kind": "MyResource",
+		  "metadata": {"name": "demo", "namespace": "default", "uid": "0b3d2c1a", "creationTimestamp": null},
+		  "spec": {"message": "k2star0118/practice-gin-gonic:v0.0.1", "someValue": 2, "containerPort": 8080,
+		           "resources": {}, "service": {"type": "NodePort", "port": 80}},
+		  "status": {"availableReplicas": 1, "serviceDNSName": "demo.default.svc"}
+		}`, string(review.Response.ConvertedObjects[0].Raw))
+	}
+}
+
+func TestConversionHandlerFailure(t *testing.T) {
+	var review ConversionReview
+	assert.Equal(t, http.StatusOK, post(t, NewConversionHandler(), `{
+	  "apiVersion": "apiextensions.k8s.io/v1",
+	  "kind": "ConversionReview",
+	  "request": {"uid": "2", "desiredAPIVersion": "trstringer.com/v3",
+	              "objects": [{"apiVersion": "trstringer.com/v1", "kind": "MyResource"}]}
+	}`, &review))
+	assert.Equal(t, meta_v1.StatusFailure, review.Response.Result.Status)
+	assert.Contains(t, review.Response.Result.Message, "trstringer.com/v3")
+	assert.Empty(t, review.Response.ConvertedObjects)
+
+	assert.Equal(t, http.StatusBadRequest, post(t, NewConversionHandler(), `{"kind": "ConversionReview"}`, &review))
+	assert.Equal(t, http.StatusBadRequest, post(t, NewConversionHandler(), `not json`, &review))
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	admission_v1beta1 "k8s.io/api/admission/v1beta1"
+
+	"k8s-controller-custom-resource/jsonpatch"
+	myresource_v1 "k8s-controller-custom-resource/pkg/apis/myresource/v1"
+)
+
+// MutatePath is the path of the mutating webhook
+const MutatePath = "/mutate"
+
+// NewMutatingHandler returns the handler of the mutating webhook. It
+// writes the defaults the controller applies into the MyResource, with
+// the standard labels, and moves the deprecated message and someValue to
+// spec.image and spec.http.methods
+func NewMutatingHandler() http.Handler {
+	return newAdmissionHandler(func(request *admission_v1beta1.AdmissionRequest) *admission_v1beta1.AdmissionResponse {
+		if request.SubResource != "" ||
+			(request.Operation != admission_v1beta1.Create && request.Operation != admission_v1beta1.Update) {
+			return allow()
+		}
+		resource, err := decodeMyResource(request.Object)
+		if err != nil {
+			return deny(http.StatusBadRequest, err.Error())
+		}
+		var original map[string]interface{}
+		if err := json.Unmarshal(request.Object.Raw, &original); err != nil {
+			return deny(http.StatusBadRequest, err.Error())
+		}
+
+		myresource_v1.SetObjectDefaults_MyResource(resource)
+		data, err := json.Marshal(resource)
+		if err != nil {
+			return deny(http.StatusInternalServerError, err.Error())
+		}
+		var defaulted map[string]interface{}
+		if err := json.Unmarshal(data, &defaulted); err != nil {
+			return deny(http.StatusInternalServerError, err.Error())
+		}
+
+		operations := jsonpatch.Diff("", mutableFields(defaulted), mutableFields(original))
+		if len(operations) == 0 {
+			return allow()
+		}
+		patch, err := json.Marshal(operations)
+		if err != nil {
+			return deny(http.StatusInternalServerError, err.Error())
+		}
+		log.Infof("Defaulted the %s of myresource %s/%s: %s", request.Operation, request.Namespace, resource.Name, patch)
+		patchType := admission_v1beta1.PatchTypeJSONPatch
+		return &admission_v1beta1.AdmissionResponse{Allowed: true, Patch: patch, PatchType: &patchType}
+	})
+}
+
+// mutableFields returns the labels and the spec of a MyResource in the
+// unstructured form, the webhook leaves the rest alone
+func mutableFields(object map[string]interface{}) map[string]interface{} {
+	metadata := map[string]interface{}{}
+	if objectMetadata, ok := object["metadata"].(map[string]interface{}); ok {
+		if labels, ok := objectMetadata["labels"]; ok {
+			metadata["labels"] = labels
+		}
+	}
+	fields := map[string]interface{}{"metadata": metadata}
+	if spec, ok := object["spec"]; ok {
+		fields["spec"] = spec
+	}
+	return fields
+}
@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/stretchr/testify/assert"
+	admission_v1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	myresource_v1 "k8s-controller-custom-resource/pkg/apis/myresource/v1"
+)
+
+// mutate sends the review to the mutating webhook and returns the response
+// with the object patched by it
+func mutate(t *testing.T, review string, object string) (*admission_v1beta1.AdmissionResponse, *myresource_v1.MyResource) {
+	var out admission_v1beta1.AdmissionReview
+	assert.Equal(t, http.StatusOK, post(t, NewMutatingHandler(), review, &out))
+	if !assert.NotNil(t, out.Response) {
+		t.FailNow()
+	}
+	assert.True(t, out.Response.Allowed)
+
+	patched := []byte(object)
+	if out.Response.Patch != nil {
+		assert.Equal(t, admission_v1beta1.PatchTypeJSONPatch, *out.Response.PatchType)
+		patch, err := jsonpatch.DecodePatch(out.Response.Patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if patched, err = patch.Apply(patched); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resource := &myresource_v1.MyResource{}
+	if err := json.Unmarshal(patched, resource); err != nil {
+		t.Fatal(err)
+	}
+	return out.Response, resource
+}
+
+func TestMutatingHandlerFillsDefaults(t *testing.T) {
+	object := myResource("demo", `{"message": "k2star0118/practice-gin-gonic:v0.0.1", "someValue": 3,
+	  "ingress": {"host": "demo.example.com"}}`)
+	response, resource := mutate(t, admissionReview("CREATE", object, ""), object)
+	assert.Equal(t, "b1c0ffee-0000-4000-8000-000000000001", string(response.UID))
+
+	// the same as the controller applies to a MyResource from before the webhook
+	expected, err := decodeMyResource(runtime.RawExtension{Raw: []byte(object)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	myresource_v1.SetObjectDefaults_MyResource(expected)
+	assert.Equal(t, expected, resource)
+
+	assert.Equal(t, map[string]string{
+		"app.kubernetes.io/name":     "myresource",
+		"app.kubernetes.io/instance": "demo",
+	}, resource.Labels)
+	spec := resource.Spec
+	assert.Equal(t, "k2star0118/practice-gin-gonic:v0.0.1", spec.Image)
+	assert.Equal(t, "", spec.Message)
+	assert.Nil(t, spec.SomeValue)
+	assert.Equal(t, []myresource_v1.HTTPMethod{"GET", "PUT"}, spec.HTTP.Methods)
+	assert.Equal(t, int32(1), *spec.Replicas)
+	assert.Equal(t, int32(8888), spec.ContainerPort)
+	assert.Equal(t, "IfNotPresent", string(spec.ImagePullPolicy))
+	assert.Equal(t, &myresource_v1.ServiceSpec{Type: "ClusterIP", Port: 80}, spec.Service)
+	assert.Equal(t, "/", spec.Ingress.Path)
+	assert.Equal(t, myresource_v1.DeletionPolicyDelete, spec.DeletionPolicy)
+}
+
+func TestMutatingHandlerPatch(t *testing.T) {
+	object := `{"apiVersion": "trstringer.com/v1", "kind": "MyResource",
+	  "metadata": {"name": "demo", "namespace": "default", "labels": {"app.kubernetes.io/name": "web"}},
+	  "spec": {"image": "nginx:1.15", "imagePullPolicy": "IfNotPresent", "replicas": 2, "containerPort": 8888,
+	           "someValue": 2, "http": {"methods": ["GET"]}, "service": {"type": "ClusterIP", "port": 80},
+	           "deletionPolicy": "Retain"}}`
+	var out admission_v1beta1.AdmissionReview
+	assert.Equal(t, http.StatusOK, post(t, NewMutatingHandler(), admissionReview("UPDATE", object, object), &out))
+	assert.JSONEq(t, `[
+	  {"op": "add", "path": "/metadata/labels/app.kubernetes.io~1instance", "value": "demo"},
+	  {"op": "remove", "path": "/spec/someValue"}
+	]`, string(out.Response.Patch))
+
+	// nothing to do for a defaulted MyResource
+	_, resource := mutate(t, admissionReview("UPDATE", object, object), object)
+	data, err := json.Marshal(resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, _ := mutate(t, admissionReview("UPDATE", string(data), object), string(data))
+	assert.Nil(t, response.Patch)
+	assert.Nil(t, response.PatchType)
+}
+
+func TestMutatingHandlerIgnoresOtherRequests(t *testing.T) {
+	response, _ := mutate(t, admissionReview("DELETE", "null", myResource("demo", "{}")), "{}")
+	assert.Nil(t, response.Patch)
+}
@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	admission_v1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	corelister_v1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	myresource_v1 "k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	"k8s-controller-custom-resource/service"
+)
+
+// ValidatePath is the path of the validating webhook
+const ValidatePath = "/validate"
+
+// NewValidatingHandler returns the handler of the validating webhook, it
+// rejects the MyResources the controller would refuse to reconcile with the
+// same rules. The node port of a new Service must not be allocated to
+// another Service of the cluster, services reads them from the cache of an
+// informer once synced says so, a nil lister skips the check
+func NewValidatingHandler(services corelister_v1.ServiceLister, synced cache.InformerSynced) http.Handler {
+	return newAdmissionHandler(func(request *admission_v1beta1.AdmissionRequest) *admission_v1beta1.AdmissionResponse {
+		if request.SubResource != "" {
+			return allow()
+		}
+		var old *myresource_v1.MyResource
+		switch request.Operation {
+		case admission_v1beta1.Create:
+		case admission_v1beta1.Update:
+			var err error
+			if old, err = decodeMyResource(request.OldObject); err != nil {
+				return deny(http.StatusBadRequest, err.Error())
+			}
+		default:
+			return allow()
+		}
+		resource, err := decodeMyResource(request.Object)
+		if err != nil {
+			return deny(http.StatusBadRequest, err.Error())
+		}
+
+		var errs field.ErrorList
+		if old == nil {
+			errs = service.ValidateMyResource(resource)
+		} else {
+			errs = service.ValidateMyResourceUpdate(resource, old)
+		}
+		if services != nil {
+			nodePortErrs, err := validateNodePort(services, synced, resource, old)
+			if err != nil {
+				return deny(http.StatusServiceUnavailable, err.Error())
+			}
+			errs = append(errs, nodePortErrs...)
+		}
+		if len(errs) == 0 {
+			return allow()
+		}
+
+		log.Infof("Rejected the %s of myresource %s/%s: %v", request.Operation, request.Namespace, resource.Name,
+			errs.ToAggregate())
+		status := errors.NewInvalid(schema.GroupKind{Group: myResourceKind.Group, Kind: myResourceKind.Kind},
+			resource.Name, errs).ErrStatus
+		return &admission_v1beta1.AdmissionResponse{Result: &status}
+	})
+}
+
+// validateNodePort returns an error when the node port of the Service of
+// the resource is allocated to another Service, and fails while the cache
+// of the Services has not synced
+func validateNodePort(services corelister_v1.ServiceLister, synced cache.InformerSynced,
+	resource, old *myresource_v1.MyResource) (field.ErrorList, error) {
+	if resource.Spec.Service == nil || resource.Spec.Service.NodePort == 0 {
+		return nil, nil
+	}
+	nodePort := resource.Spec.Service.NodePort
+	if old != nil && old.Spec.Service != nil && old.Spec.Service.NodePort == nodePort {
+		return nil, nil
+	}
+
+	if !synced() {
+		return nil, fmt.Errorf("the Services of the cluster have not been listed yet, retry later")
+	}
+	list, err := services.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the Services: \n%v", err)
+	}
+	for _, svc := range list {
+		// the Service of the resource itself
+		if svc.Namespace == resource.Namespace && svc.Name == resource.Name {
+			continue
+		}
+		for _, port := range svc.Spec.Ports {
+			if port.NodePort == nodePort {
+				return field.ErrorList{field.Invalid(field.NewPath("spec", "service", "nodePort"), nodePort,
+					fmt.Sprintf("is already allocated to the Service %s/%s", svc.Namespace, svc.Name))}, nil
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admission_v1beta1 "k8s.io/api/admission/v1beta1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelister_v1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// admissionReview returns an AdmissionReview of a MyResource, oldObject is
+// only sent for updates
+func admissionReview(operation, object, oldObject string) string {
+	review := `{
+	  "apiVersion": "admission.k8s.io/v1beta1",
+	  "kind": "AdmissionReview",
+	  "request": {
+	    "uid": "b1c0ffee-0000-4000-8000-000000000001",
+	    "kind": {"group": "trstringer.com", "version": "v1", "kind": "MyResource"},
+	    "resource": {"group": "trstringer.com", "version": "v1", "resource": "myresources"},
+	    "namespace": "default",
+	    "operation": "%s",
+	    "userInfo": {"username": "admin"},
+	    "object": %s`
+	if oldObject != "" {
+		review += `,
+	    "oldObject": ` + oldObject
+	}
+	return fmt.Sprintf(review+"}}", operation, object)
+}
+
+func myResource(name, spec string) string {
+	return fmt.Sprintf(`{"apiVersion": "trstringer.com/v1", "kind": "MyResource",
+	  "metadata": {"name": %q, "namespace": "default"}, "spec": %s}`, name, spec)
+}
+
+// serviceLister returns a lister of a synced cache with the Services
+func serviceLister(t *testing.T, services ...*core_v1.Service) corelister_v1.ServiceLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, svc := range services {
+		assert.Nil(t, indexer.Add(svc))
+	}
+	return corelister_v1.NewServiceLister(indexer)
+}
+
+func synced() bool { return true }
+
+func validate(t *testing.T, review string) *admission_v1beta1.AdmissionResponse {
+	services := serviceLister(t, &core_v1.Service{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "web", Namespace: "team-a"},
+		Spec: core_v1.ServiceSpec{
+			Type:  core_v1.ServiceTypeNodePort,
+			Ports: []core_v1.ServicePort{{Port: 80, NodePort: 30080}},
+		},
+	})
+	var out admission_v1beta1.AdmissionReview
+	assert.Equal(t, http.StatusOK, post(t, NewValidatingHandler(services, synced), review, &out))
+	if !assert.NotNil(t, out.Response) {
+		t.FailNow()
+	}
+	assert.Equal(t, "b1c0ffee-0000-4000-8000-000000000001", string(out.Response.UID))
+	return out.Response
+}
+
+// causes returns the field and the message of every cause of the response
+func causes(response *admission_v1beta1.AdmissionResponse) map[string]string {
+	fields := map[string]string{}
+	if response.Result != nil && response.Result.Details != nil {
+		for _, cause := range response.Result.Details.Causes {
+			fields[cause.Field] = cause.Message
+		}
+	}
+	return fields
+}
+
+func TestValidatingHandlerAllowsValidResource(t *testing.T) {
+	response := validate(t, admissionReview("CREATE", myResource("demo",
+		`{"image": "k2star0118/practice-gin-gonic:v0.0.1", "containerPort": 8888,
+		  "service": {"type": "NodePort", "port": 80, "nodePort": 30081}}`), ""))
+	assert.True(t, response.Allowed)
+	assert.Nil(t, response.Result)
+}
+
+func TestValidatingHandlerRejectsCreate(t *testing.T) {
+	response := validate(t, admissionReview("CREATE", myResource(strings.Repeat("a", 64),
+		`{"image": "nginx:", "replicas": -1, "http": {"methods": ["GET", "TRACE"]},
+		  "service": {"type": "NodePort", "nodePort": 30080}}`), ""))
+	assert.False(t, response.Allowed)
+	assert.Equal(t, meta_v1.StatusReasonInvalid, response.Result.Reason)
+	assert.Equal(t, int32(http.StatusUnprocessableEntity), response.Result.Code)
+	assert.Equal(t, "MyResource", response.Result.Details.Kind)
+	assert.Equal(t, "trstringer.com", response.Result.Details.Group)
+
+	fields := causes(response)
+	assert.Len(t, fields, 5)
+	assert.Contains(t, fields["metadata.name"], "63")
+	assert.Contains(t, fields["spec.image"], "image reference")
+	assert.Contains(t, fields["spec.replicas"], "must not be negative")
+	assert.Contains(t, fields["spec.http.methods[1]"], `"TRACE"`)
+	assert.Contains(t, fields["spec.service.nodePort"], "already allocated to the Service team-a/web")
+}
+
+func TestValidatingHandlerWaitsForServiceCache(t *testing.T) {
+	review := admissionReview("CREATE", myResource("demo",
+		`{"image": "nginx", "service": {"type": "NodePort", "nodePort": 30081}}`), "")
+	var out admission_v1beta1.AdmissionReview
+	handler := NewValidatingHandler(serviceLister(t), func() bool { return false })
+	assert.Equal(t, http.StatusOK, post(t, handler, review, &out))
+	assert.False(t, out.Response.Allowed)
+	assert.Equal(t, int32(http.StatusServiceUnavailable), out.Response.Result.Code)
+
+	// without a node port the cache is not needed
+	review = admissionReview("CREATE", myResource("demo", `{"image": "nginx"}`), "")
+	out = admission_v1beta1.AdmissionReview{}
+	assert.Equal(t, http.StatusOK, post(t, handler, review, &out))
+	assert.True(t, out.Response.Allowed)
+}
+
+func TestValidatingHandlerRejectsUpdate(t *testing.T) {
+	old := myResource("demo", `{"message": "nginx", "someValue": 1}`)
+
+	// moving to the new fields is fine, and so is changing the deprecated ones
+	response := validate(t, admissionReview("UPDATE", myResource("demo",
+		`{"image": "nginx", "http": {"methods": ["GET"]}}`), old))
+	assert.True(t, response.Allowed)
+	response = validate(t, admissionReview("UPDATE", myResource("demo",
+		`{"message": "httpd", "someValue": 2}`), old))
+	assert.True(t, response.Allowed)
+	response = validate(t, admissionReview("UPDATE", myResource("demo",
+		`{"message": "httpd:", "someValue": 2}`), old))
+	assert.False(t, response.Allowed)
+	assert.Equal(t, []string{"spec.message"}, keys(causes(response)))
+
+	// a spec which was valid before the webhook existed can still lose its finalizer
+	invalid := myResource("demo", `{"message": "nginx", "replicas": -1}`)
+	response = validate(t, admissionReview("UPDATE", invalid, invalid))
+	assert.True(t, response.Allowed)
+}
+
+func TestValidatingHandlerIgnoresOtherRequests(t *testing.T) {
+	response := validate(t, admissionReview("DELETE", "null", ""))
+	assert.True(t, response.Allowed)
+
+	response = validate(t, strings.Replace(admissionReview("CREATE", myResource("demo", "{}"), ""),
+		`"version": "v1", "kind": "MyResource"`, `"version": "v2", "kind": "MyResource"`, 1))
+	assert.False(t, response.Allowed)
+	assert.Contains(t, response.Result.Message, "expected a trstringer.com/v1, Kind=MyResource")
+
+	var out admission_v1beta1.AdmissionReview
+	assert.Equal(t, http.StatusBadRequest, post(t, NewValidatingHandler(nil, nil), `{"kind": "AdmissionReview"}`, &out))
+}
+
+func keys(m map[string]string) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
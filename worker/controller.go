@@ -2,89 +2,245 @@ package worker
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
+
+	"k8s-controller-custom-resource/metrics"
 )
 
-const maxRetries = 5
+// defaultMaxRetries is the number of retries of a failed key when the
+// Controller does not set MaxRetries
+const defaultMaxRetries = 5
+
+// ReasonGaveUp is the reason of the event recorded when a MyResource failed
+// more than MaxRetries times
+const ReasonGaveUp = "ReconcileGaveUp"
 
-// Event indicate the informerEvent
-type Event struct {
-	Key       string
-	EventType string
-	OldObj    interface {}
+// requeueError makes the Controller process the key again after a delay
+// without counting it as a failed attempt, for reconciles that have to wait
+// for the cluster to catch up
+type requeueError struct {
+	after  time.Duration
+	reason string
+}
+
+func (e *requeueError) Error() string {
+	return fmt.Sprintf("requeue after %v: %s", e.after, e.reason)
 }
 
 // Controller struct defines how a controller should encapsulate
 // logging, client connectivity, informing (list and watching)
-// queueing, and handling of resource changes
+// queueing, and reconciling of resource changes
 type Controller struct {
 	Logger    *log.Entry
 	Clientset kubernetes.Interface
 	Queue     workqueue.RateLimitingInterface
 	Informer  cache.SharedIndexInformer
-	Handler   Handler
+	// DeploymentInformer watches the Deployments owned by MyResources, it
+	// is built from Clientset when it is not set
+	DeploymentInformer cache.SharedIndexInformer
+	// ServiceInformer watches the Services owned by MyResources, it is
+	// built from Clientset when it is not set
+	ServiceInformer cache.SharedIndexInformer
+	// IngressInformer watches the Ingresses owned by MyResources, it is
+	// built from Clientset when it is not set
+	IngressInformer cache.SharedIndexInformer
+	Reconciler      Reconciler
+	// Recorder records events on the MyResources, like giving up on one
+	// after MaxRetries, it should be shared with the Reconciler
+	Recorder record.EventRecorder
+	// Workers is the number of keys reconciled in parallel, the Queue
+	// never hands the same key to two workers at once (defaults to 1)
+	Workers int
+	// MaxRetries is how often a failed key is retried before the
+	// Controller gives up on it (defaults to 5)
+	MaxRetries int
+	// CacheSyncTimeout is how long Run waits for the informers to sync
+	// before it fails, 0 waits until stopCh is closed
+	CacheSyncTimeout time.Duration
+	// StallTimeout is how long a worker may reconcile a single key before
+	// Healthy reports the worker loop as stalled (defaults to 5 minutes)
+	StallTimeout time.Duration
+
+	// processing holds when the workers took the keys in flight
+	processingLock sync.Mutex
+	processing     map[interface{}]time.Time
 }
 
-// Run is the main path of execution for the controller loop
-func (c *Controller) Run(stopCh <-chan struct{}) {
+// Run is the main path of execution for the controller loop, it blocks
+// until stopCh is closed and every worker has finished its current item.
+// It returns an error when the informers do not sync in CacheSyncTimeout
+func (c *Controller) Run(stopCh <-chan struct{}) error {
 	// handle a panic with logging and exiting
 	defer utilruntime.HandleCrash()
-	// ignore new items in the Queue but when all goroutines
-	// have completed existing items then shutdown
-	defer c.Queue.ShutDown()
 
 	c.Logger.Info("Controller.Run: initiating")
 
 	// run the Informer to start listing and watching resources
 	go c.Informer.Run(stopCh)
 
+	// watch the owned Deployments, Services and Ingresses so that changes
+	// to them requeue their MyResource
+	if c.DeploymentInformer == nil && c.Clientset != nil {
+		c.DeploymentInformer = NewDeploymentInformer(c.Clientset, metav1.NamespaceAll, 0)
+	}
+	if c.ServiceInformer == nil && c.Clientset != nil {
+		c.ServiceInformer = NewServiceInformer(c.Clientset, metav1.NamespaceAll, 0)
+	}
+	if c.IngressInformer == nil && c.Clientset != nil {
+		c.IngressInformer = NewIngressInformer(c.Clientset, metav1.NamespaceAll, 0)
+	}
+	for _, informer := range c.ownedInformers() {
+		informer.AddEventHandler(c.ownerHandler())
+		go informer.Run(stopCh)
+	}
+
 	// do the initial synchronization (one time) to populate resources
-	if !cache.WaitForCacheSync(stopCh, c.HasSynced) {
-		utilruntime.HandleError(fmt.Errorf("error syncing cache"))
-		return
+	if !cache.WaitForCacheSync(c.syncStopCh(stopCh), c.HasSynced) {
+		c.Queue.ShutDown()
+		select {
+		case <-stopCh:
+			c.Logger.Info("Controller.Run: stopped before the cache synced")
+			return nil
+		default:
+			return fmt.Errorf("error syncing cache: timed out after %v", c.CacheSyncTimeout)
+		}
 	}
 	c.Logger.Info("Controller.Run: cache sync complete")
 
+	workers := c.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
 	// run the runWorker method every second with a stop channel
-	wait.Until(c.runWorker, time.Second, stopCh)
+	// in each of the workers
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait.Until(func() { c.runWorker(stopCh) }, time.Second, stopCh)
+		}()
+	}
+	c.Logger.Infof("Controller.Run: started %d workers", workers)
+
+	<-stopCh
+	c.Logger.Info("Controller.Run: waiting for workers to finish")
+	// ignore new items in the Queue and wake up the workers
+	// blocked in Get so that they can return
+	c.Queue.ShutDown()
+	wg.Wait()
+	c.Logger.Info("Controller.Run: stopped")
+	return nil
+}
+
+// syncStopCh returns a channel closed with stopCh or once the
+// CacheSyncTimeout has passed
+func (c *Controller) syncStopCh(stopCh <-chan struct{}) <-chan struct{} {
+	if c.CacheSyncTimeout <= 0 {
+		return stopCh
+	}
+	syncStopCh := make(chan struct{})
+	go func() {
+		defer close(syncStopCh)
+		timer := time.NewTimer(c.CacheSyncTimeout)
+		defer timer.Stop()
+		select {
+		case <-stopCh:
+		case <-timer.C:
+		}
+	}()
+	return syncStopCh
 }
 
 // HasSynced allows us to satisfy the Controller interface
-// by wiring up the Informer's HasSynced method to it
+// by wiring up the Informers' HasSynced methods to it
 func (c *Controller) HasSynced() bool {
+	for _, informer := range c.ownedInformers() {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
 	return c.Informer.HasSynced()
 }
 
+// ownedInformers returns the informers set on the children of MyResources
+func (c *Controller) ownedInformers() []cache.SharedIndexInformer {
+	var informers []cache.SharedIndexInformer
+	for _, informer := range []cache.SharedIndexInformer{c.DeploymentInformer, c.ServiceInformer, c.IngressInformer} {
+		if informer != nil {
+			informers = append(informers, informer)
+		}
+	}
+	return informers
+}
+
+func (c *Controller) maxRetries() int {
+	if c.MaxRetries <= 0 {
+		return defaultMaxRetries
+	}
+	return c.MaxRetries
+}
+
+// recordGiveUp records a Warning event on the MyResource of the key when the
+// Controller stops retrying it, the next change to it is retried again
+func (c *Controller) recordGiveUp(key string, err error) {
+	if c.Recorder == nil || c.Informer == nil {
+		return
+	}
+	obj, exists, getErr := c.Informer.GetIndexer().GetByKey(key)
+	if getErr != nil || !exists {
+		return
+	}
+	if object, ok := obj.(runtime.Object); ok {
+		c.Recorder.Eventf(object, apiv1.EventTypeWarning, ReasonGaveUp,
+			"Giving up after %d retries: %v", c.maxRetries(), err)
+	}
+}
+
 // runWorker executes the loop to process new items added to the Queue
-func (c *Controller) runWorker() {
+// until the Queue is shut down or stopCh is closed
+func (c *Controller) runWorker(stopCh <-chan struct{}) {
 	log.Info("Controller.runWorker: starting")
 
 	// invoke processNextItem to fetch and consume the next change
 	// to a watched or listed resource
 	for c.processNextItem() {
+		select {
+		case <-stopCh:
+			// leave the rest of the Queue, only the item
+			// in flight is finished on shutdown
+			log.Info("Controller.runWorker: stopping")
+			return
+		default:
+		}
 		log.Info("Controller.runWorker: processing next item")
 	}
 
 	log.Info("Controller.runWorker: completed")
 }
 
-// processNextItem retrieves each queued item and takes the
-// necessary Handler action based off of if the item was
-// created or deleted
+// processNextItem retrieves each queued key and hands it to
+// the Reconciler, requeueing it with rate limiting on failure
 func (c *Controller) processNextItem() bool {
 	log.Info("Controller.processNextItem: start")
 
-	// fetch the next item (blocking) from the Queue to process or
+	// fetch the next key (blocking) from the Queue to process or
 	// if a shutdown is requested then return out of this to stop
 	// processing
-	newEvent, quit := c.Queue.Get()
+	key, quit := c.Queue.Get()
 
 	// stop the worker loop from running as this indicates we
 	// have sent a shutdown message that the Queue has indicated
@@ -92,43 +248,32 @@ func (c *Controller) processNextItem() bool {
 	if quit {
 		return false
 	}
-	defer c.Queue.Done(newEvent)
-	err := c.processItem(newEvent.(Event))
-	if err == nil {
+	defer c.Queue.Done(key)
+	c.startProcessing(key)
+	defer c.finishProcessing(key)
+	start := time.Now()
+	err := c.Reconciler.Reconcile(key.(string))
+	if requeue, ok := err.(*requeueError); ok {
+		c.Logger.Infof("Requeue %s after %v: %s", key, requeue.after, requeue.reason)
+		c.Queue.Forget(key)
+		c.Queue.AddAfter(key, requeue.after)
+		metrics.RecordReconcile(metrics.OutcomeRequeue, time.Since(start))
+	} else if err == nil {
 		// No error, reset the ratelimit counters
-		c.Queue.Forget(newEvent)
-	} else if c.Queue.NumRequeues(newEvent) < maxRetries {
-		c.Logger.Errorf("Error processing %s (will retry):\n%v", newEvent.(Event).Key, err)
-		c.Queue.AddRateLimited(newEvent)
+		c.Queue.Forget(key)
+		metrics.RecordReconcile(metrics.OutcomeSuccess, time.Since(start))
+	} else if c.Queue.NumRequeues(key) < c.maxRetries() {
+		c.Logger.Errorf("Error processing %s (will retry):\n%v", key, err)
+		c.Queue.AddRateLimited(key)
+		metrics.RecordReconcile(metrics.OutcomeError, time.Since(start))
 	} else {
 		// err != nil and too many retries
-		c.Logger.Errorf("Error processing %s (giving up):\n%v", newEvent.(Event).Key, err)
-		c.Queue.Forget(newEvent)
+		c.Logger.Errorf("Error processing %s (giving up):\n%v", key, err)
+		c.Queue.Forget(key)
 		utilruntime.HandleError(err)
+		c.recordGiveUp(key.(string), err)
+		metrics.RecordReconcile(metrics.OutcomeDropped, time.Since(start))
 	}
 
 	return true
 }
-
-func (c *Controller) processItem(newEvent Event) error {
-	item, _, err := c.Informer.GetIndexer().GetByKey(newEvent.Key)
-	if err != nil {
-		return fmt.Errorf("error fetching object with key %s from store:\n%v", newEvent.Key, err)
-	}
-
-	// process events based on its type
-	switch newEvent.EventType {
-	case "create":
-		c.Handler.ObjectCreated(item)
-		return nil
-	case "update":
-		c.Handler.ObjectUpdated(newEvent.OldObj, item)
-		return nil
-	case "delete":
-		log.Infof("Old obj is:\n%v", newEvent.OldObj)
-		c.Handler.ObjectDeleted(newEvent.OldObj)
-		return nil
-	}
-
-	return nil
-}
@@ -0,0 +1,206 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// fakeReconciler records the reconciled keys and fails with err when it is set
+type fakeReconciler struct {
+	keys []string
+	err  error
+}
+
+func (r *fakeReconciler) Init() error { return nil }
+
+func (r *fakeReconciler) Reconcile(key string) error {
+	r.keys = append(r.keys, key)
+	return r.err
+}
+
+func newTestController(reconciler Reconciler) *Controller {
+	return &Controller{
+		Logger: log.NewEntry(log.New()),
+		// no backoff so that requeued items are immediately available
+		Queue:      workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(0, 0)),
+		Reconciler: reconciler,
+	}
+}
+
+// newEmptyInformer returns a MyResource informer which never sees any objects
+func newEmptyInformer() cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &v1.MyResourceList{}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+	}, &v1.MyResource{}, 0, cache.Indexers{})
+}
+
+func TestProcessNextItemSuccess(t *testing.T) {
+	reconciler := &fakeReconciler{}
+	c := newTestController(reconciler)
+	c.Queue.Add("default/demo")
+
+	assert.True(t, c.processNextItem())
+	assert.Equal(t, []string{"default/demo"}, reconciler.keys)
+	assert.Equal(t, 0, c.Queue.Len())
+	assert.Equal(t, 0, c.Queue.NumRequeues("default/demo"))
+}
+
+func TestQueueCoalescesKeys(t *testing.T) {
+	reconciler := &fakeReconciler{}
+	c := newTestController(reconciler)
+	// add, update and delete of the same resource before the worker runs
+	c.Queue.Add("default/demo")
+	c.Queue.Add("default/demo")
+	c.Queue.Add("default/demo")
+
+	assert.Equal(t, 1, c.Queue.Len())
+	assert.True(t, c.processNextItem())
+	assert.Equal(t, []string{"default/demo"}, reconciler.keys)
+}
+
+func TestProcessNextItemRetriesThenGivesUp(t *testing.T) {
+	reconciler := &fakeReconciler{err: errors.New("apiserver unavailable")}
+	c := newTestController(reconciler)
+	c.Queue.Add("default/demo")
+
+	// the first attempt plus defaultMaxRetries rate-limited requeues
+	for i := 0; i <= defaultMaxRetries; i++ {
+		assert.True(t, c.processNextItem())
+	}
+	assert.Len(t, reconciler.keys, defaultMaxRetries+1)
+	assert.Equal(t, 0, c.Queue.Len())
+	assert.Equal(t, 0, c.Queue.NumRequeues("default/demo"))
+}
+
+func TestProcessNextItemGiveUpRecordsEvent(t *testing.T) {
+	reconciler := &fakeReconciler{err: errors.New("apiserver unavailable")}
+	recorder := record.NewFakeRecorder(10)
+	c := newTestController(reconciler)
+	c.Recorder = recorder
+	c.MaxRetries = 1
+	c.Informer = newEmptyInformer()
+	assert.Nil(t, c.Informer.GetIndexer().Add(&v1.MyResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+	}))
+	c.Queue.Add("default/demo")
+
+	// retries are not recorded, only giving up
+	assert.True(t, c.processNextItem())
+	assert.Len(t, recorder.Events, 0)
+	assert.True(t, c.processNextItem())
+	assert.Equal(t, "Warning ReconcileGaveUp Giving up after 1 retries: apiserver unavailable", <-recorder.Events)
+}
+
+// concurrencyReconciler tracks how many keys, and how many times the same
+// key, are reconciled at once
+type concurrencyReconciler struct {
+	mu        sync.Mutex
+	active    map[string]int
+	running   int
+	maxActive int
+	maxPerKey int
+	done      int
+}
+
+func (r *concurrencyReconciler) Init() error { return nil }
+
+func (r *concurrencyReconciler) Reconcile(key string) error {
+	r.mu.Lock()
+	r.active[key]++
+	r.running++
+	if r.active[key] > r.maxPerKey {
+		r.maxPerKey = r.active[key]
+	}
+	if r.running > r.maxActive {
+		r.maxActive = r.running
+	}
+	r.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	r.mu.Lock()
+	r.active[key]--
+	r.running--
+	r.done++
+	r.mu.Unlock()
+	return nil
+}
+
+func TestRunWithWorkers(t *testing.T) {
+	reconciler := &concurrencyReconciler{active: map[string]int{}}
+	c := newTestController(reconciler)
+	c.Workers = 4
+	c.Informer = newEmptyInformer()
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		c.Run(stopCh)
+	}()
+
+	for i := 0; i < 8; i++ {
+		c.Queue.Add(fmt.Sprintf("default/demo-%d", i))
+		// keep re-adding the same key while it may be in flight
+		c.Queue.Add("default/shared")
+		time.Sleep(2 * time.Millisecond)
+	}
+	err := wait.PollImmediate(5*time.Millisecond, time.Second, func() (bool, error) {
+		return c.Queue.Len() == 0, nil
+	})
+	assert.Nil(t, err)
+
+	close(stopCh)
+	<-doneCh
+
+	reconciler.mu.Lock()
+	defer reconciler.mu.Unlock()
+	assert.Equal(t, 0, reconciler.running, "Run returned with items in flight")
+	assert.Equal(t, 1, reconciler.maxPerKey, "a key was reconciled by two workers at once")
+	assert.True(t, reconciler.maxActive > 1, "workers did not run in parallel")
+}
+
+func TestProcessNextItemMaxRetries(t *testing.T) {
+	reconciler := &fakeReconciler{err: errors.New("apiserver unavailable")}
+	c := newTestController(reconciler)
+	c.MaxRetries = 2
+	c.Queue.Add("default/demo")
+
+	for i := 0; i <= c.MaxRetries; i++ {
+		assert.True(t, c.processNextItem())
+	}
+	assert.Len(t, reconciler.keys, c.MaxRetries+1)
+	assert.Equal(t, 0, c.Queue.Len())
+}
+
+func TestProcessNextItemRequeueIsNotAFailure(t *testing.T) {
+	reconciler := &fakeReconciler{err: &requeueError{after: time.Millisecond, reason: "waiting"}}
+	c := newTestController(reconciler)
+	c.Queue.Add("default/demo")
+
+	// more attempts than defaultMaxRetries would allow for failures
+	for i := 0; i <= defaultMaxRetries+1; i++ {
+		assert.True(t, c.processNextItem())
+	}
+	assert.Len(t, reconciler.keys, defaultMaxRetries+2)
+	assert.Equal(t, 0, c.Queue.NumRequeues("default/demo"))
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultStallTimeout is how long a worker may reconcile a single key when
+// the Controller does not set StallTimeout
+const defaultStallTimeout = 5 * time.Minute
+
+// Ready returns an error until the informers of the Controller have synced
+func (c *Controller) Ready() error {
+	if !c.HasSynced() {
+		return fmt.Errorf("informers have not synced")
+	}
+	return nil
+}
+
+// Healthy returns an error when a worker has been reconciling the same key
+// for longer than the StallTimeout, which means the worker loop is stuck
+func (c *Controller) Healthy() error {
+	timeout := c.StallTimeout
+	if timeout <= 0 {
+		timeout = defaultStallTimeout
+	}
+
+	c.processingLock.Lock()
+	defer c.processingLock.Unlock()
+	for key, start := range c.processing {
+		if elapsed := time.Since(start); elapsed > timeout {
+			return fmt.Errorf("worker stalled reconciling %v for %v", key, elapsed.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// startProcessing records that a worker took the key from the Queue, the
+// Queue never hands the same key to two workers at once
+func (c *Controller) startProcessing(key interface{}) {
+	c.processingLock.Lock()
+	defer c.processingLock.Unlock()
+	if c.processing == nil {
+		c.processing = map[interface{}]time.Time{}
+	}
+	c.processing[key] = time.Now()
+}
+
+func (c *Controller) finishProcessing(key interface{}) {
+	c.processingLock.Lock()
+	defer c.processingLock.Unlock()
+	delete(c.processing, key)
+}
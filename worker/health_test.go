@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// blockingReconciler blocks every reconcile until release is closed
+type blockingReconciler struct {
+	started chan string
+	release chan struct{}
+}
+
+func (r *blockingReconciler) Init() error { return nil }
+
+func (r *blockingReconciler) Reconcile(key string) error {
+	r.started <- key
+	<-r.release
+	return nil
+}
+
+func TestHealthyDetectsStalledWorker(t *testing.T) {
+	reconciler := &blockingReconciler{started: make(chan string, 1), release: make(chan struct{})}
+	c := newTestController(reconciler)
+	c.StallTimeout = 20 * time.Millisecond
+	c.Queue.Add("default/demo")
+
+	assert.Nil(t, c.Healthy())
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		c.processNextItem()
+	}()
+	<-reconciler.started
+	assert.Nil(t, c.Healthy(), "a reconcile in flight is not stalled yet")
+
+	err := wait.PollImmediate(5*time.Millisecond, time.Second, func() (bool, error) {
+		return c.Healthy() != nil, nil
+	})
+	assert.Nil(t, err)
+	assert.Contains(t, c.Healthy().Error(), "worker stalled reconciling default/demo")
+
+	close(reconciler.release)
+	<-doneCh
+	assert.Nil(t, c.Healthy())
+}
+
+func TestRunFailsWhenCacheDoesNotSync(t *testing.T) {
+	c := newTestController(&fakeReconciler{})
+	c.Informer = cache.NewSharedIndexInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return nil, errors.New("forbidden")
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+	}, &v1.MyResource{}, 0, cache.Indexers{})
+	c.CacheSyncTimeout = 50 * time.Millisecond
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	assert.NotNil(t, c.Ready())
+	err := c.Run(stopCh)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "error syncing cache")
+	}
+	assert.NotNil(t, c.Ready())
+}
+
+func TestRunStopsBeforeCacheSync(t *testing.T) {
+	c := newTestController(&fakeReconciler{})
+	c.Informer = newEmptyInformer()
+	c.CacheSyncTimeout = time.Minute
+
+	stopCh := make(chan struct{})
+	close(stopCh)
+	assert.Nil(t, c.Run(stopCh))
+}
+
+func TestReadyAfterCacheSync(t *testing.T) {
+	c := newTestController(&fakeReconciler{})
+	c.Informer = newEmptyInformer()
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		c.Run(stopCh)
+	}()
+	err := wait.PollImmediate(5*time.Millisecond, time.Second, func() (bool, error) {
+		return c.Ready() == nil, nil
+	})
+	assert.Nil(t, err)
+
+	close(stopCh)
+	<-doneCh
+}
@@ -0,0 +1,105 @@
+package worker
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	appsinformer_v1 "k8s.io/client-go/informers/apps/v1"
+	coreinformer_v1 "k8s.io/client-go/informers/core/v1"
+	extensionsinformer_v1beta1 "k8s.io/client-go/informers/extensions/v1beta1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+)
+
+// NewDeploymentInformer returns a shared informer on the Deployments of the
+// namespace, the Controller requeues the MyResource controlling a Deployment
+// whenever it changes so that hand edits and deletions are reverted
+func NewDeploymentInformer(client kubernetes.Interface, namespace string, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return appsinformer_v1.NewDeploymentInformer(
+		client,
+		namespace,
+		resyncPeriod,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+}
+
+// NewServiceInformer returns a shared informer on the Services of the
+// namespace, they are requeued like the Deployments
+func NewServiceInformer(client kubernetes.Interface, namespace string, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return coreinformer_v1.NewServiceInformer(
+		client,
+		namespace,
+		resyncPeriod,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+}
+
+// NewIngressInformer returns a shared informer on the Ingresses of the
+// namespace, they are requeued like the Deployments
+func NewIngressInformer(client kubernetes.Interface, namespace string, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return extensionsinformer_v1beta1.NewIngressInformer(
+		client,
+		namespace,
+		resyncPeriod,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+}
+
+// ownerKey returns the 'namespace/name' key of the MyResource controlling
+// the object, deleted objects may come as a tombstone
+func ownerKey(obj interface{}) (string, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	object, ok := obj.(metav1.Object)
+	if !ok {
+		return "", false
+	}
+	controllerRef := metav1.GetControllerOf(object)
+	if controllerRef == nil || controllerRef.Kind != "MyResource" {
+		return "", false
+	}
+	groupVersion, err := schema.ParseGroupVersion(controllerRef.APIVersion)
+	if err != nil || groupVersion.Group != v1.SchemeGroupVersion.Group {
+		return "", false
+	}
+	return object.GetNamespace() + "/" + controllerRef.Name, true
+}
+
+// ownerHandler enqueues the key of the MyResource controlling a changed
+// object, objects without such an owner are filtered out
+func (c *Controller) ownerHandler() cache.ResourceEventHandler {
+	enqueue := func(obj interface{}) {
+		if key, ok := ownerKey(obj); ok {
+			c.Queue.Add(key)
+		}
+	}
+	return cache.FilteringResourceEventHandler{
+		FilterFunc: func(obj interface{}) bool {
+			_, ok := ownerKey(obj)
+			return ok
+		},
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc: enqueue,
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				if oldObj.(metav1.Object).GetResourceVersion() == newObj.(metav1.Object).GetResourceVersion() {
+					// periodic resync, the MyResource informer resyncs on its own
+					return
+				}
+				log.Infof("Update owned object: %s", newObj.(metav1.Object).GetName())
+				// a released object changes its owner, both have to know
+				enqueue(oldObj)
+				enqueue(newObj)
+			},
+			DeleteFunc: func(obj interface{}) {
+				key, _ := ownerKey(obj)
+				log.Infof("Delete owned object of myresource: %s", key)
+				enqueue(obj)
+			},
+		},
+	}
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s-controller-custom-resource/pkg/apis/myresource/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newOwnedDeployment(ownerRef *metav1.OwnerReference) *appsv1.Deployment {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-deployment", Namespace: "team-a", ResourceVersion: "1"},
+	}
+	if ownerRef != nil {
+		deployment.OwnerReferences = []metav1.OwnerReference{*ownerRef}
+	}
+	return deployment
+}
+
+func newMyResourceRef(name string) *metav1.OwnerReference {
+	owner := &v1.MyResource{ObjectMeta: metav1.ObjectMeta{Name: name, UID: "uid-a"}}
+	return metav1.NewControllerRef(owner, v1.SchemeGroupVersion.WithKind("MyResource"))
+}
+
+func TestOwnerKey(t *testing.T) {
+	key, ok := ownerKey(newOwnedDeployment(newMyResourceRef("demo")))
+	assert.True(t, ok)
+	assert.Equal(t, "team-a/demo", key)
+
+	key, ok = ownerKey(cache.DeletedFinalStateUnknown{
+		Key: "team-a/demo-deployment",
+		Obj: newOwnedDeployment(newMyResourceRef("demo")),
+	})
+	assert.True(t, ok)
+	assert.Equal(t, "team-a/demo", key)
+
+	_, ok = ownerKey(newOwnedDeployment(nil))
+	assert.False(t, ok)
+
+	// same kind name in another API group
+	foreign := newMyResourceRef("demo")
+	foreign.APIVersion = "example.com/v1"
+	_, ok = ownerKey(newOwnedDeployment(foreign))
+	assert.False(t, ok)
+
+	// owned, but not as the controller
+	notController := newMyResourceRef("demo")
+	notController.Controller = nil
+	_, ok = ownerKey(newOwnedDeployment(notController))
+	assert.False(t, ok)
+}
+
+// keyReconciler sends every reconciled key to a channel
+type keyReconciler struct {
+	keys chan string
+}
+
+func (r *keyReconciler) Init() error { return nil }
+
+func (r *keyReconciler) Reconcile(key string) error {
+	r.keys <- key
+	return nil
+}
+
+func nextKey(t *testing.T, keys <-chan string) string {
+	select {
+	case key := <-keys:
+		return key
+	case <-time.After(3 * time.Second):
+		t.Fatal("no key was reconciled")
+		return ""
+	}
+}
+
+func TestRunRequeuesOwnerOfChangedDeployment(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		newOwnedDeployment(newMyResourceRef("demo")),
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "team-a"}},
+	)
+	reconciler := &keyReconciler{keys: make(chan string, 10)}
+	c := newTestController(reconciler)
+	c.Clientset = client
+	c.Informer = newEmptyInformer()
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		c.Run(stopCh)
+	}()
+
+	// the initial list of the owned Deployment
+	assert.Equal(t, "team-a/demo", nextKey(t, reconciler.keys))
+
+	// deleting it by hand requeues its owner
+	err := client.AppsV1().Deployments("team-a").Delete("demo-deployment", &metav1.DeleteOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "team-a/demo", nextKey(t, reconciler.keys))
+
+	close(stopCh)
+	<-doneCh
+	assert.Empty(t, reconciler.keys, "a Deployment without owner was enqueued")
+}
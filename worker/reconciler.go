@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+
+	myresourcelister_v1 "k8s-controller-custom-resource/pkg/client/listers/myresource/v1"
+	"k8s-controller-custom-resource/service"
+)
+
+// Reconciler interface contains the methods that are required. Reconcile is
+// level-triggered: it only gets the 'namespace/name' key and must converge the
+// cluster to the desired state of the object currently in the cache, a returned
+// error makes the Controller requeue the key with rate limiting
+type Reconciler interface {
+	Init() error
+	Reconcile(key string) error
+}
+
+// MyResourceReconciler is a sample implementation of Reconciler
+type MyResourceReconciler struct {
+	Lister  myresourcelister_v1.MyResourceLister
+	Service *service.HttpService
+}
+
+// Init handles any Reconciler initialization
+func (r *MyResourceReconciler) Init() error {
+	log.Info("MyResourceReconciler.Init")
+	return nil
+}
+
+// deletionRecheckPeriod is how often a deleted MyResource checks whether
+// its child resources are gone
+const deletionRecheckPeriod = 2 * time.Second
+
+// Reconcile creates or updates the Deployment of an existing MyResource. A
+// deleted MyResource keeps its finalizer until the Deployment is handled
+// according to the deletion policy
+func (r *MyResourceReconciler) Reconcile(key string) error {
+	log.Infof("MyResourceReconciler.Reconcile: %s", key)
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return fmt.Errorf("invalid resource key %s:\n%v", key, err)
+	}
+
+	myResource, err := r.Lister.MyResources(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		log.Infof("MyResource %s no longer exists", key)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching object with key %s from store:\n%v", key, err)
+	}
+
+	// objects from the lister are shared with the informer cache, so never
+	// hand them out for modification
+	myResource = myResource.DeepCopy()
+
+	if myResource.DeletionTimestamp != nil {
+		done, err := r.Service.FinalizeHttp(myResource)
+		if err != nil {
+			return err
+		}
+		if !done {
+			return &requeueError{after: deletionRecheckPeriod, reason: "waiting for child resources to be deleted"}
+		}
+		return nil
+	}
+
+	myResource, err = r.Service.EnsureFinalizer(myResource)
+	if err != nil {
+		return err
+	}
+	return r.Service.ReconcileHttp(myResource)
+}